@@ -0,0 +1,93 @@
+package cors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// AdminHandler returns an http.Handler managing the origins of p:
+//
+//	GET    /origins                 lists the managed origins
+//	POST   /origins/allowed         adds an allowed origin
+//	DELETE /origins/allowed         removes an allowed origin
+//	POST   /origins/denied          adds a denied origin
+//	DELETE /origins/denied          removes a denied origin
+//	POST   /groups/{group}/origins  adds an origin to a group
+//	DELETE /groups/{group}/origins  removes an origin from a group
+//
+// Changes take a JSON body like {"origin": "https://foo.com", "reason":
+// "..."} and respond with the managed origins once applied. Invalid origins
+// are rejected with a 400 status.
+//
+// The handler doesn't authenticate requests: protect it with a middleware,
+// which should set the actor recorded in the audit log with WithActor. Use
+// http.StripPrefix to mount it under a path.
+func (p *ReloadablePolicy) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /origins", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, p.Origins())
+	})
+	handle := func(pattern string, change func(ctx context.Context, r *http.Request, origin, reason string) error) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Origin string `json:"origin"`
+				Reason string `json:"reason"`
+			}
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil || body.Origin == "" {
+				writeAdminError(w, http.StatusBadRequest, errors.New("cors: expected a JSON body with an origin"))
+				return
+			}
+			if err := change(r.Context(), r, body.Origin, body.Reason); err != nil {
+				writeAdminError(w, adminErrorStatus(err), err)
+				return
+			}
+			writeAdminJSON(w, http.StatusOK, p.Origins())
+		})
+	}
+	handle("POST /origins/allowed", func(ctx context.Context, r *http.Request, origin, reason string) error {
+		return p.Allow(ctx, origin, reason)
+	})
+	handle("DELETE /origins/allowed", func(ctx context.Context, r *http.Request, origin, reason string) error {
+		return p.RemoveAllowed(ctx, origin, reason)
+	})
+	handle("POST /origins/denied", func(ctx context.Context, r *http.Request, origin, reason string) error {
+		return p.Deny(ctx, origin, reason)
+	})
+	handle("DELETE /origins/denied", func(ctx context.Context, r *http.Request, origin, reason string) error {
+		return p.RemoveDenied(ctx, origin, reason)
+	})
+	handle("POST /groups/{group}/origins", func(ctx context.Context, r *http.Request, origin, reason string) error {
+		return p.AddToGroup(ctx, r.PathValue("group"), origin, reason)
+	})
+	handle("DELETE /groups/{group}/origins", func(ctx context.Context, r *http.Request, origin, reason string) error {
+		return p.RemoveFromGroup(ctx, r.PathValue("group"), origin, reason)
+	})
+	return mux
+}
+
+// adminErrorStatus returns the status of the response to a failed change.
+func adminErrorStatus(err error) int {
+	var originErr *OriginError
+	switch {
+	case errors.As(err, &originErr):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrUnknownGroup), errors.Is(err, ErrOriginNotListed):
+		return http.StatusNotFound
+	case errors.Is(err, ErrOriginListed):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeAdminJSON(w, status, map[string]string{"error": err.Error()})
+}
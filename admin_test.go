@@ -0,0 +1,77 @@
+package cors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	var actors []string
+	p, err := NewReloadablePolicy(context.Background(), Options{
+		OriginGroups: []OriginGroup{{Name: "partners"}},
+	}, ReloadablePolicyOptions{
+		AuditLog: AuditLogFunc(func(ctx context.Context, e AuditEntry) error {
+			actors = append(actors, e.Actor)
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(w, r.WithContext(WithActor(r.Context(), "admin")))
+		})
+	}
+	h := http.StripPrefix("/admin", auth(p.AdminHandler()))
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/admin/origins/allowed", `{"origin": "https://foo.com", "reason": "demo"}`, http.StatusOK},
+		{http.MethodPost, "/admin/origins/allowed", `{"origin": "https://foo.com"}`, http.StatusConflict},
+		{http.MethodPost, "/admin/origins/allowed", `{"origin": "https://foo.com/path"}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/origins/allowed", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/origins/allowed", `not json`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/origins/denied", `{"origin": "https://bar.com"}`, http.StatusOK},
+		{http.MethodDelete, "/admin/origins/denied", `{"origin": "https://bar.com"}`, http.StatusOK},
+		{http.MethodDelete, "/admin/origins/denied", `{"origin": "https://bar.com"}`, http.StatusNotFound},
+		{http.MethodPost, "/admin/groups/partners/origins", `{"origin": "https://acme.com"}`, http.StatusOK},
+		{http.MethodPost, "/admin/groups/vendors/origins", `{"origin": "https://acme.com"}`, http.StatusNotFound},
+		{http.MethodPut, "/admin/origins/allowed", `{"origin": "https://baz.com"}`, http.StatusMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if res.Code != tc.status {
+			t.Errorf("%s %s %s: got status %d, want %d: %s", tc.method, tc.path, tc.body, res.Code, tc.status, res.Body)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/origins", nil)
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	var got ManagedOrigins
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := ManagedOrigins{
+		AllowedOrigins: []string{"https://foo.com"},
+		Groups:         map[string][]string{"partners": {"https://acme.com"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got origins %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(actors, []string{"admin", "admin", "admin", "admin"}) {
+		t.Errorf("got audit actors %v", actors)
+	}
+}
@@ -0,0 +1,75 @@
+package cors
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Actions and lists of the changes recorded in an AuditEntry.
+const (
+	AuditAdd    = "add"
+	AuditRemove = "remove"
+	// AuditReload records a reload of the origins from the PolicyStore.
+	AuditReload = "reload"
+	// AuditUpdate records a change of the base options.
+	AuditUpdate = "update"
+
+	AuditAllowed = "allowed"
+	AuditDenied  = "denied"
+	AuditGroup   = "group"
+)
+
+// An AuditEntry records a change of a ReloadablePolicy.
+type AuditEntry struct {
+	// Time of the change.
+	Time time.Time `json:"time"`
+	// Actor who made the change, as set with WithActor.
+	Actor string `json:"actor,omitempty"`
+	// Reason given for the change.
+	Reason string `json:"reason,omitempty"`
+	// Action is AuditAdd, AuditRemove, AuditReload or AuditUpdate.
+	Action string `json:"action"`
+	// List is AuditAllowed, AuditDenied or AuditGroup for AuditAdd and
+	// AuditRemove actions.
+	List string `json:"list,omitempty"`
+	// Group is the name of the changed group when List is AuditGroup.
+	Group string `json:"group,omitempty"`
+	// Origin added or removed by AuditAdd and AuditRemove actions.
+	Origin string `json:"origin,omitempty"`
+	// Diff describes the resulting changes of the effective policy.
+	Diff *PolicyDiff `json:"diff,omitempty"`
+}
+
+// An AuditLog records the changes of a ReloadablePolicy. A change whose
+// entry can't be recorded is aborted.
+type AuditLog interface {
+	Record(ctx context.Context, e AuditEntry) error
+}
+
+// AuditLogFunc is an adapter to use a function as an AuditLog.
+type AuditLogFunc func(ctx context.Context, e AuditEntry) error
+
+// Record calls f(ctx, e).
+func (f AuditLogFunc) Record(ctx context.Context, e AuditEntry) error {
+	return f(ctx, e)
+}
+
+// NewJSONAuditLog returns an AuditLog writing each entry to w as a line of
+// JSON. Write errors are returned, aborting the change.
+func NewJSONAuditLog(w io.Writer) AuditLog {
+	return &jsonAuditLog{enc: json.NewEncoder(w)}
+}
+
+type jsonAuditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (l *jsonAuditLog) Record(ctx context.Context, e AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(e)
+}
@@ -0,0 +1,79 @@
+package cors
+
+import (
+	"math"
+	"net/http"
+	"sync/atomic"
+)
+
+// Canary applies a candidate policy to a percentage of the origins, the
+// other ones staying on the current policy. Origins are assigned by hashing,
+// so a given origin consistently gets the same policy, and the origins on the
+// candidate at a percentage stay on it at higher percentages, allowing a
+// gradual ramp up. Use its Resolve method as Options.PolicyResolver of the
+// current policy, and name both policies with Options.Name to tell them apart
+// in the Decisions. It is safe for concurrent use.
+type Canary struct {
+	candidate atomic.Pointer[Cors]
+	// Percentage of the origins on the candidate, in hundredths of percent
+	basisPoints atomic.Int64
+}
+
+// NewCanary creates a Canary applying candidate to percent percents of the
+// origins.
+func NewCanary(candidate *Cors, percent float64) *Canary {
+	c := &Canary{}
+	c.candidate.Store(candidate)
+	c.SetPercent(percent)
+	return c
+}
+
+// SetPercent changes the percentage of the origins on the candidate, clamped
+// between 0 and 100.
+func (c *Canary) SetPercent(percent float64) {
+	bp := int64(math.Round(min(max(percent, 0), 100) * 100))
+	c.basisPoints.Store(bp)
+}
+
+// Percent returns the percentage of the origins on the candidate.
+func (c *Canary) Percent() float64 {
+	return float64(c.basisPoints.Load()) / 100
+}
+
+// SetCandidate replaces the candidate policy.
+func (c *Canary) SetCandidate(candidate *Cors) {
+	c.candidate.Store(candidate)
+}
+
+// Resolve returns the candidate policy for the requests from origins on the
+// candidate, and nil for the other ones so they are handled with the
+// current policy.
+func (c *Canary) Resolve(r *http.Request) (*Cors, error) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil, nil
+	}
+	if canaryBucket(origin) < c.basisPoints.Load() {
+		return c.candidate.Load(), nil
+	}
+	return nil, nil
+}
+
+// canaryBucket hashes origin with FNV-1a into one of 10000 buckets. ASCII
+// letters are lowercased so equivalent origins share their bucket.
+func canaryBucket(origin string) int64 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(origin); i++ {
+		b := origin[i]
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		h ^= uint32(b)
+		h *= prime32
+	}
+	return int64(h % 10000)
+}
@@ -0,0 +1,109 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanaryZeroPercent(t *testing.T) {
+	candidate := New(Options{AllowedOrigins: []string{"https://foo.com"}})
+	for i, options := range []Options{
+		{},
+		{AllowedOrigins: []string{"https://bar.com"}},
+		{Rules: []Rule{{Origins: []string{"https://bar.com"}}}},
+	} {
+		current := New(options)
+		options.PolicyResolver = NewCanary(candidate, 0).Resolve
+		withCanary := New(options)
+		for _, origin := range []string{"https://foo.com", "https://bar.com", "https://baz.com"} {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+			req.Header.Add("Origin", origin)
+			if got, want := withCanary.OriginAllowed(req), current.OriginAllowed(req); got != want {
+				t.Errorf("options #%d: origin %q allowed = %t with a canary at 0%%, want %t", i, origin, got, want)
+			}
+		}
+	}
+}
+
+func TestCanary(t *testing.T) {
+	candidate := New(Options{
+		Name:             "candidate",
+		AllowedOrigins:   []string{"https://*"},
+		AllowCredentials: true,
+	})
+	canary := NewCanary(candidate, 0)
+	var decisions []Decision
+	s := New(Options{
+		Name:           "current",
+		AllowedOrigins: []string{"https://*"},
+		PolicyResolver: canary.Resolve,
+		DecisionHooks: []DecisionHook{DecisionHookFunc(func(r *http.Request, d Decision) {
+			decisions = append(decisions, d)
+		})},
+	})
+	h := s.Handler(testHandler)
+
+	origins := make([]string, 1000)
+	for i := range origins {
+		origins[i] = fmt.Sprintf("https://site%d.com", i)
+	}
+	onCandidate := func() map[string]bool {
+		decisions = decisions[:0]
+		for _, origin := range origins {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+			req.Header.Add("Origin", origin)
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+			credentials := res.Header().Get("Access-Control-Allow-Credentials") == "true"
+			d := decisions[len(decisions)-1]
+			if (d.Policy == "candidate") != credentials || d.Origin != origin || !d.Allowed {
+				t.Fatalf("decision %+v doesn't match the response", d)
+			}
+		}
+		m := map[string]bool{}
+		for _, d := range decisions {
+			if d.Policy == "candidate" {
+				m[d.Origin] = true
+			}
+		}
+		return m
+	}
+
+	if got := onCandidate(); len(got) != 0 {
+		t.Errorf("got %d origins on the candidate at 0%%", len(got))
+	}
+	canary.SetPercent(10)
+	ten := onCandidate()
+	if len(ten) < 50 || len(ten) > 150 {
+		t.Errorf("got %d origins out of 1000 on the candidate at 10%%", len(ten))
+	}
+	if got := onCandidate(); len(got) != len(ten) {
+		t.Error("origins should consistently get the same policy")
+	}
+	canary.SetPercent(50)
+	fifty := onCandidate()
+	for origin := range ten {
+		if !fifty[origin] {
+			t.Errorf("origin %s left the candidate when ramping up", origin)
+		}
+	}
+	canary.SetPercent(150)
+	if canary.Percent() != 100 {
+		t.Errorf("got percent %v, want 100", canary.Percent())
+	}
+	if got := onCandidate(); len(got) != len(origins) {
+		t.Errorf("got %d origins on the candidate at 100%%", len(got))
+	}
+}
+
+func TestCanaryBucket(t *testing.T) {
+	if canaryBucket("https://FOO.com") != canaryBucket("https://foo.com") {
+		t.Error("case variants of an origin should share their bucket")
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	if c, _ := NewCanary(Default(), 100).Resolve(req); c != nil {
+		t.Error("requests without origin should stay on the current policy")
+	}
+}
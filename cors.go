@@ -21,16 +21,17 @@ The resulting handler is a standard net/http handler.
 package cors
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"slices"
-	"strconv"
 	"strings"
-
-	"github.com/rs/cors/internal"
 )
 
+// nullOrigin is the serialization of an opaque origin.
+const nullOrigin = "null"
+
 var (
 	headerVaryOrigin = []string{"Origin"}
 	headerOriginAll  = []string{"*"}
@@ -44,8 +45,74 @@ type Options struct {
 	// An origin may contain a wildcard (*) to replace 0 or more characters
 	// (i.e.: http://*.domain.com). Usage of wildcards implies a small performance penalty.
 	// Only one wildcard can be used per origin.
+	// The port of an origin may be a wildcard or a range of ports
+	// (i.e.: http://localhost:* or http://127.0.0.1:3000-3999), in which case
+	// it only matches a valid port of the Origin. A port wildcard also
+	// matches an origin using the default port of its scheme.
+	// The host of an origin may be an IP prefix in CIDR notation
+	// (i.e.: http://10.0.0.0/8 or https://[fd00::]/8:*) to allow origins whose
+	// host is an IP address within that prefix; host names never match.
+	// Origins are compared in their normalized form: case-insensitively,
+	// ignoring the default port of the scheme (i.e.: https://foo.com:443 is
+	// equal to https://foo.com), with Unicode host names converted to
+	// punycode and IPv6 literals in their canonical form.
+	// An origin may omit its scheme, either as a host (i.e.: example.com or
+	// *.example.com) or as a scheme relative origin (i.e.: //example.com:8443),
+	// in which case it is allowed for each of the DefaultSchemes.
+	// Origins of browser extensions and hybrid mobile apps (chrome-extension,
+	// moz-extension, safari-web-extension, capacitor and ionic schemes) are
+	// validated according to their scheme and only accept a wildcard for the
+	// whole host (i.e.: moz-extension://*); see ChromeExtension,
+	// AnyMozExtension and similar helpers.
+	// Invalid or ambiguous origins are ignored; use Options.Validate to detect
+	// them.
 	// Default value is ["*"]
 	AllowedOrigins []string
+	// DeniedOrigins is a list of origins denied cross-domain requests, using
+	// the same syntax as AllowedOrigins (except "*"). It is evaluated before
+	// any allow rule, including AllowedOrigins, AllowOriginFunc and its
+	// variants, so it can carve exceptions out of a wildcard
+	// (i.e.: deny https://sandbox.example.com while allowing
+	// https://*.example.com).
+	DeniedOrigins []string
+	// Rules is an ordered list of rules, each allowing or denying a set of
+	// origins with its own methods, headers, credentials and max-age. The
+	// first rule matching the origin of a request decides. Rules are
+	// evaluated after DeniedOrigins and before the other origin options
+	// (AllowedOrigins, AllowOriginFunc...), which act as a final rule using
+	// the settings of these options. When rules are set, an empty
+	// AllowedOrigins no longer allows all origins.
+	Rules []Rule
+	// OriginGroups is a list of named groups of origins, each with settings
+	// (methods, exposed headers, max-age and credentials) overriding the
+	// options for the requests from its origins. Groups are evaluated in
+	// order after Rules and before the other origin options, the response
+	// headers being computed from the first group matching the origin.
+	// When groups are set, an empty AllowedOrigins no longer allows all
+	// origins.
+	OriginGroups []OriginGroup
+	// PolicyResolver, when set, resolves the Cors handler whose policy
+	// applies to a request, allowing whole policies (origins, methods,
+	// headers, credentials...) to vary per request, i.e.: per tenant; see
+	// PolicyCache. The handling of preflight requests (OptionsPassthrough and
+	// OptionsSuccessStatus) remains the one of these options. If the resolver
+	// returns nil, the request is handled with the policy of these options
+	// which, as without resolver, allows all origins when AllowedOrigins is
+	// empty. If it fails, the origin of the request is rejected, so an outage
+	// of the policy source doesn't open CORS to every origin.
+	PolicyResolver func(r *http.Request) (*Cors, error)
+	// DevelopmentOrigins allows any localhost origin (localhost, 127.0.0.1,
+	// [::1] and *.localhost on any port with the http and https schemes) in
+	// addition to AllowedOrigins. As a safety net against shipping it to
+	// production, it only takes effect when the CORS_DEVELOPMENT environment
+	// variable is set to a true value, and a warning is logged when it does.
+	// It is ignored when all origins are allowed or when an AllowOriginFunc
+	// is set.
+	DevelopmentOrigins bool
+	// DefaultSchemes lists the schemes used to expand AllowedOrigins entries
+	// that do not specify a scheme.
+	// Default value is ["http", "https"].
+	DefaultSchemes []string
 	// AllowOriginFunc is a custom function to validate the origin. It take the
 	// origin as argument and returns true if allowed or false otherwise. If
 	// this option is set, the content of `AllowedOrigins` is ignored.
@@ -65,6 +132,14 @@ type Options struct {
 	// option is set, the contents of `AllowedOrigins`, `AllowOriginFunc` and
 	// `AllowOriginRequestFunc` are ignored.
 	AllowOriginVaryRequestFunc func(r *http.Request, origin string) (bool, []string)
+	// OriginStore decides whether origins are allowed from an external
+	// source like a database. Origins are looked up in their normalized form
+	// (see AllowedOrigins). Stores performing I/O should be wrapped with
+	// NewOriginStoreCache so requests don't wait for it. Lookup errors are
+	// logged and the answer returned with the error is used. If this option
+	// is set, the content of `AllowedOrigins` is ignored, and it is itself
+	// ignored if `AllowOriginFunc` or its variants are set.
+	OriginStore OriginStore
 	// AllowedMethods is a list of methods the client is allowed to use with
 	// cross-domain requests. Default value is simple methods (HEAD, GET and POST).
 	AllowedMethods []string
@@ -98,6 +173,61 @@ type Options struct {
 	Debug bool
 	// Adds a custom logger, implies Debug is true
 	Logger Logger
+	// LogSampling limits the number of requests logged per origin, so the
+	// logs of requests can stay enabled in production.
+	LogSampling LogSampling
+	// AllowNullOrigin allows requests with the opaque "null" origin, as sent by
+	// sandboxed iframes, file:// pages and some redirects. Any page can send
+	// such an origin, so allowing it is a known CORS pitfall: New logs a
+	// warning when it is set. See NullOriginPolicy to restrict what the null
+	// origin is granted. Listing "null" in AllowedOrigins is equivalent.
+	AllowNullOrigin bool
+	// NullOriginPolicy controls how requests with the "null" origin are
+	// handled.
+	NullOriginPolicy NullOriginPolicy
+	// Name identifies the policy in the Decisions, i.e. to tell apart the
+	// policies returned by a PolicyResolver.
+	Name string
+	// DecisionHooks are notified of the Decision taken for each CORS request
+	// handled by this handler, including the requests handled with a policy
+	// returned by PolicyResolver (whose own hooks are not called).
+	DecisionHooks []DecisionHook
+	// DecisionContext attaches the Decision taken for each request to the
+	// context of the request passed to the next handler, where FromContext
+	// retrieves it. It is disabled by default as it costs allocations on
+	// every request.
+	DecisionContext bool
+	// DecisionHints computes the Hint of the Decisions passed to
+	// DecisionHooks or attached to the request context. It is disabled by
+	// default as it costs a walk over the allowed origins for every request
+	// from an origin which is not allowed. For the requests handled with a
+	// policy returned by PolicyResolver, the option of that policy applies.
+	DecisionHints bool
+	// CSRFProtection rejects the cross-site state-changing requests from
+	// origins not allowed by the policy instead of passing them to the next
+	// handler. Preflight requests are not affected.
+	CSRFProtection CSRFProtection
+
+	// Set by ReloadablePolicy once its last origin is removed, so an empty
+	// AllowedOrigins allows no origin
+	noOrigins bool
+}
+
+// NullOriginPolicy controls how requests with the "null" origin are handled.
+// The zero value never grants credentials to the null origin.
+type NullOriginPolicy struct {
+	// EchoOrigin responds with "Access-Control-Allow-Origin: null" instead of
+	// "*" when all origins are allowed. When only the null origin is allowed,
+	// "null" is always echoed.
+	EchoOrigin bool `json:"echoOrigin"`
+	// AllowCredentials grants credentials to the null origin when
+	// AllowCredentials is set. It is implied by the deprecated "null" entry
+	// of AllowedOrigins.
+	AllowCredentials bool `json:"allowCredentials"`
+	// ExcludeFromAllowAll rejects the null origin when all origins are
+	// allowed (i.e.: AllowedOrigins is empty or contains "*"), unless
+	// AllowNullOrigin is set.
+	ExcludeFromAllowAll bool `json:"excludeFromAllowAll"`
 }
 
 // Logger generic interface for logger
@@ -109,47 +239,75 @@ type Logger interface {
 type Cors struct {
 	// Debug logger
 	Log Logger
-	// Normalized list of plain allowed origins
-	allowedOrigins []string
-	// List of allowed origins containing wildcards
-	allowedWOrigins []wildcard
+	// Settings of the origin options, used by the final rule
+	policy
+	// Origin rules, evaluated in order
+	rules []*rule
+	// Normalized list of allowed origins
+	allowedOrigins originList
 	// Optional origin validator function
 	allowOriginFunc func(r *http.Request, origin string) (bool, []string)
-	// Normalized list of allowed headers
-	// Note: the Fetch standard guarantees that CORS-unsafe request-header names
-	// (i.e. the values listed in the Access-Control-Request-Headers header)
-	// are unique and sorted;
-	// see https://fetch.spec.whatwg.org/#cors-unsafe-request-header-names.
-	allowedHeaders internal.SortedSet
-	// Normalized list of allowed methods
-	allowedMethods []string
-	// Pre-computed normalized list of exposed headers
-	exposedHeaders []string
-	// Pre-computed maxAge header value
-	maxAge []string
+	// Optional per request policy resolver
+	policyResolver func(r *http.Request) (*Cors, error)
+	// Policy rejecting all origins, applied when the resolver fails
+	unresolved *Cors
 	// Set to true when allowed origins contains a "*"
 	allowedOriginsAll bool
-	// Set to true when allowed headers contains a "*"
-	allowedHeadersAll bool
+	// Set to true when the "null" origin is explicitly allowed
+	allowNullOrigin bool
+	nullOrigin      NullOriginPolicy
 	// Status code to use for successful OPTIONS requests
 	optionsSuccessStatus int
-	allowCredentials     bool
 	allowPrivateNetwork  bool
 	optionPassthrough    bool
 	preflightVary        []string
+	// Name of the policy, reported in the decisions
+	name            string
+	decisionHooks   []DecisionHook
+	decisionContext bool
+	decisionHints   bool
+	// Optional CSRF protection
+	csrf *csrfProtection
+	// Optional sampler of the logs of requests
+	logSampler *logSampler
 }
 
 // New creates a new Cors handler with the provided options.
 func New(options Options) *Cors {
 	c := &Cors{
-		allowCredentials:    options.AllowCredentials,
 		allowPrivateNetwork: options.AllowPrivateNetwork,
+		policyResolver:      options.PolicyResolver,
 		optionPassthrough:   options.OptionsPassthrough,
+		allowNullOrigin:     options.AllowNullOrigin,
+		nullOrigin:          options.NullOriginPolicy,
+		name:                options.Name,
+		decisionHooks:       options.DecisionHooks,
+		decisionContext:     options.DecisionContext,
+		decisionHints:       options.DecisionHints,
 		Log:                 options.Logger,
 	}
+	c.logSampler = newLogSampler(options.LogSampling, c.logf)
 	if options.Debug && c.Log == nil {
 		c.Log = log.New(os.Stdout, "[cors] ", log.LstdFlags)
 	}
+	if c.policyResolver != nil {
+		c.unresolved = New(Options{
+			AllowOriginFunc: func(origin string) bool { return false },
+			Name:            options.Name,
+			Logger:          c.Log,
+		})
+	}
+	var csrfErrs []error
+	c.csrf, csrfErrs = newCSRFProtection(options.CSRFProtection)
+	for _, err := range csrfErrs {
+		c.warnf("%v", err)
+	}
+	c.allowCredentials = options.AllowCredentials
+
+	schemes := options.DefaultSchemes
+	if len(schemes) == 0 {
+		schemes = defaultSchemes
+	}
 
 	// Allowed origins
 	switch {
@@ -163,58 +321,77 @@ func New(options Options) *Cors {
 		c.allowOriginFunc = func(r *http.Request, origin string) (bool, []string) {
 			return options.AllowOriginFunc(origin), nil
 		}
-	case len(options.AllowedOrigins) == 0:
+	case options.OriginStore != nil:
+		c.allowOriginFunc = func(r *http.Request, origin string) (bool, []string) {
+			// Equivalent serializations of an origin share their answer
+			// (and cache entry), malformed origins are never looked up
+			normalized, ok := normalizeOrigin(origin)
+			if !ok {
+				return false, nil
+			}
+			allowed, err := options.OriginStore.Lookup(r.Context(), normalized)
+			if err != nil {
+				c.rlogf(r, "  Origin store lookup failed for '%s': %v", origin, err)
+			}
+			return allowed, nil
+		}
+	case len(options.AllowedOrigins) == 0 && !options.hasOriginOptions():
 		if c.allowOriginFunc == nil {
 			// Default is all origins
 			c.allowedOriginsAll = true
 		}
 	default:
-		c.allowedOrigins = []string{}
-		c.allowedWOrigins = []wildcard{}
 		for _, origin := range options.AllowedOrigins {
-			// Note: for origins matching, the spec requires a case-sensitive matching.
-			// As it may error prone, we chose to ignore the spec here.
-			origin = strings.ToLower(origin)
 			if origin == "*" {
 				// If "*" is present in the list, turn the whole list into a match all
 				c.allowedOriginsAll = true
-				c.allowedOrigins = nil
-				c.allowedWOrigins = nil
+				c.allowedOrigins = originList{}
 				break
-			} else if prefix, suffix, ok := strings.Cut(origin, "*"); ok {
-				// Split the origin in two: start and end string without the *
-				w := wildcard{prefix, suffix}
-				c.allowedWOrigins = append(c.allowedWOrigins, w)
-			} else {
-				c.allowedOrigins = append(c.allowedOrigins, origin)
+			}
+			if strings.EqualFold(origin, nullOrigin) {
+				c.warnf(`"null" in AllowedOrigins is deprecated: use AllowNullOrigin instead`)
+				c.allowNullOrigin = true
+				// As before AllowNullOrigin, the listed null origin gets
+				// credentials
+				c.nullOrigin.AllowCredentials = true
+				continue
+			}
+			// Note: for origins matching, the spec requires a case-sensitive matching.
+			// As it may error prone, we chose to ignore the spec here.
+			// Origins are further normalized (default port, IDN host, IPv6
+			// literal) so equivalent serializations of an origin match.
+			if err := c.allowedOrigins.add(origin, schemes); err != nil {
+				c.warnf("%v", err)
 			}
 		}
 	}
 
-	// Allowed Headers
-	// Note: the Fetch standard guarantees that CORS-unsafe request-header names
-	// (i.e. the values listed in the Access-Control-Request-Headers header)
-	// are lowercase; see https://fetch.spec.whatwg.org/#cors-unsafe-request-header-names.
-	if len(options.AllowedHeaders) == 0 {
-		// Use sensible defaults
-		c.allowedHeaders = internal.NewSortedSet("accept", "content-type", "x-requested-with")
-	} else {
-		normalized := convert(options.AllowedHeaders, strings.ToLower)
-		c.allowedHeaders = internal.NewSortedSet(normalized...)
-		if slices.Contains(options.AllowedHeaders, "*") {
-			c.allowedHeadersAll = true
-			c.allowedHeaders = internal.SortedSet{}
+	// Development origins
+	if options.DevelopmentOrigins && c.allowOriginFunc == nil && !c.allowedOriginsAll {
+		if developmentEnabled() {
+			c.warnf("WARNING: development origins are allowed (%s is set): any localhost origin can perform cross-origin requests", DevelopmentEnv)
+			for _, origin := range developmentOrigins {
+				_ = c.allowedOrigins.add(origin, defaultSchemes)
+			}
+		} else {
+			c.logf("Development origins ignored: %s is not set", DevelopmentEnv)
 		}
 	}
 
-	// Allowed Methods
-	if len(options.AllowedMethods) == 0 {
-		// Default is spec's "simple" methods
-		c.allowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
-	} else {
-		c.allowedMethods = options.AllowedMethods
+	if c.allowNullOrigin {
+		if c.allowCredentials && c.nullOrigin.AllowCredentials {
+			c.warnf(`WARNING: the "null" origin is allowed with credentials: any sandboxed iframe or local file can perform credentialed requests`)
+		} else {
+			c.warnf(`WARNING: the "null" origin is allowed: any sandboxed iframe or local file can perform cross-origin requests`)
+		}
 	}
 
+	// Allowed Headers
+	c.setAllowedHeaders(options.AllowedHeaders)
+
+	// Allowed Methods
+	c.setAllowedMethods(options.AllowedMethods)
+
 	// Options Success Status Code
 	if options.OptionsSuccessStatus == 0 {
 		c.optionsSuccessStatus = http.StatusNoContent
@@ -223,9 +400,7 @@ func New(options Options) *Cors {
 	}
 
 	// Pre-compute exposed headers header value
-	if len(options.ExposedHeaders) > 0 {
-		c.exposedHeaders = []string{strings.Join(convert(options.ExposedHeaders, http.CanonicalHeaderKey), ", ")}
-	}
+	c.setExposedHeaders(options.ExposedHeaders)
 
 	// Pre-compute prefight Vary header to save allocations
 	if c.allowPrivateNetwork {
@@ -235,15 +410,103 @@ func New(options Options) *Cors {
 	}
 
 	// Precompute max-age
-	if options.MaxAge > 0 {
-		c.maxAge = []string{strconv.Itoa(options.MaxAge)}
-	} else if options.MaxAge < 0 {
-		c.maxAge = []string{"0"}
+	c.setMaxAge(options.MaxAge)
+
+	// Rules: denied origins first, then the rules and origin groups in
+	// order, and finally the origin options
+	// The match all origin, reported by Validate, would deny every origin
+	denied := slices.DeleteFunc(slices.Clone(options.DeniedOrigins), func(origin string) bool {
+		return origin == "*"
+	})
+	if len(denied) < len(options.DeniedOrigins) {
+		c.warnf("%v", originError("*", "the match all origin can't be listed here"))
+	}
+	if len(denied) > 0 {
+		rl, errs := c.compileRule(Rule{Name: "DeniedOrigins", Origins: denied, Deny: true}, 0, schemes)
+		c.rules = append(c.rules, rl)
+		for _, err := range errs {
+			c.warnf("%v", err)
+		}
+	}
+	for i, r := range options.Rules {
+		rl, errs := c.compileRule(r, i, schemes)
+		c.rules = append(c.rules, rl)
+		for _, err := range errs {
+			c.warnf("rule '%s': %v", rl.name, err)
+		}
+	}
+	for _, err := range validateGroups(options.OriginGroups) {
+		c.warnf("%v", err)
+	}
+	for i, g := range options.OriginGroups {
+		rl, errs := c.compileRule(g.rule(), i, schemes)
+		rl.group = true
+		c.rules = append(c.rules, rl)
+		for _, err := range errs {
+			c.warnf("group '%s': %v", g.Name, err)
+		}
+	}
+	c.rules = append(c.rules, &rule{
+		name:   "Options",
+		all:    c.allowedOriginsAll,
+		match:  c.matchOrigin,
+		policy: &c.policy,
+	})
+	if c.allowOriginFunc == nil {
+		c.rules[len(c.rules)-1].origins = &c.allowedOrigins
 	}
 
 	return c
 }
 
+// hasOriginOptions reports whether an option other than AllowedOrigins and
+// AllowOriginFunc (and its variants) allows origins, in which case an empty
+// AllowedOrigins doesn't default to all origins.
+func (o Options) hasOriginOptions() bool {
+	return o.DevelopmentOrigins || len(o.Rules) > 0 || len(o.OriginGroups) > 0 || o.noOrigins
+}
+
+// Validate reports the invalid or ambiguous entries of the options, which New
+// would otherwise ignore. The returned error wraps an *OriginError per invalid
+// origin.
+func (o Options) Validate() error {
+	schemes := o.DefaultSchemes
+	if len(schemes) == 0 {
+		schemes = defaultSchemes
+	}
+	var errs []error
+	var origins originList
+	for _, origin := range o.AllowedOrigins {
+		if origin == "*" || strings.EqualFold(origin, nullOrigin) {
+			continue
+		}
+		if err := origins.add(origin, schemes); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, origin := range o.DeniedOrigins {
+		if err := origins.add(origin, schemes); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	rules := append([]Rule(nil), o.Rules...)
+	for _, g := range o.OriginGroups {
+		rules = append(rules, g.rule())
+	}
+	for _, r := range rules {
+		for _, origin := range r.Origins {
+			if origin == "*" {
+				continue
+			}
+			if err := origins.add(origin, schemes); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	errs = append(errs, validateGroups(o.OriginGroups)...)
+	return errors.Join(errs...)
+}
+
 // Default creates a new Cors handler with default options.
 func Default() *Cors {
 	return New(Options{})
@@ -271,62 +534,87 @@ func AllowAll() *Cors {
 // as necessary.
 func (c *Cors) Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = c.sampleLogs(r)
 		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-			c.logf("Handler: Preflight request")
-			c.handlePreflight(w, r)
+			c.rlogf(r, "Handler: Preflight request")
+			d := c.handlePreflight(w, r)
 			// Preflight requests are standalone and should stop the chain as some other
 			// middleware may not handle OPTIONS requests correctly. One typical example
 			// is authentication middleware ; OPTIONS requests won't carry authentication
 			// headers (see #1)
 			if c.optionPassthrough {
-				h.ServeHTTP(w, r)
+				h.ServeHTTP(w, c.withDecision(r, d))
 			} else {
 				w.WriteHeader(c.optionsSuccessStatus)
 			}
 		} else {
-			c.logf("Handler: Actual request")
-			c.handleActualRequest(w, r)
-			h.ServeHTTP(w, r)
+			c.rlogf(r, "Handler: Actual request")
+			d := c.handleActualRequest(w, r)
+			if d.Blocked {
+				c.rejectCSRF(w)
+				return
+			}
+			h.ServeHTTP(w, c.withDecision(r, d))
 		}
 	})
 }
 
-// HandlerFunc provides Martini compatible handler
+// HandlerFunc provides Martini compatible handler. When the CSRF protection
+// rejects an actual request, HandlerFunc writes the rejection response: the
+// caller must then stop the chain instead of calling the next handler, i.e.
+// when the response has been written.
 func (c *Cors) HandlerFunc(w http.ResponseWriter, r *http.Request) {
+	r = c.sampleLogs(r)
 	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-		c.logf("HandlerFunc: Preflight request")
+		c.rlogf(r, "HandlerFunc: Preflight request")
 		c.handlePreflight(w, r)
 
 		w.WriteHeader(c.optionsSuccessStatus)
 	} else {
-		c.logf("HandlerFunc: Actual request")
-		c.handleActualRequest(w, r)
+		c.rlogf(r, "HandlerFunc: Actual request")
+		if d := c.handleActualRequest(w, r); d.Blocked {
+			c.rejectCSRF(w)
+		}
 	}
 }
 
 // Negroni compatible interface
 func (c *Cors) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	r = c.sampleLogs(r)
 	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-		c.logf("ServeHTTP: Preflight request")
-		c.handlePreflight(w, r)
+		c.rlogf(r, "ServeHTTP: Preflight request")
+		d := c.handlePreflight(w, r)
 		// Preflight requests are standalone and should stop the chain as some other
 		// middleware may not handle OPTIONS requests correctly. One typical example
 		// is authentication middleware ; OPTIONS requests won't carry authentication
 		// headers (see #1)
 		if c.optionPassthrough {
-			next(w, r)
+			next(w, c.withDecision(r, d))
 		} else {
 			w.WriteHeader(c.optionsSuccessStatus)
 		}
 	} else {
-		c.logf("ServeHTTP: Actual request")
-		c.handleActualRequest(w, r)
-		next(w, r)
+		c.rlogf(r, "ServeHTTP: Actual request")
+		d := c.handleActualRequest(w, r)
+		if d.Blocked {
+			c.rejectCSRF(w)
+			return
+		}
+		next(w, c.withDecision(r, d))
 	}
 }
 
 // handlePreflight handles pre-flight CORS requests
-func (c *Cors) handlePreflight(w http.ResponseWriter, r *http.Request) {
+func (c *Cors) handlePreflight(w http.ResponseWriter, r *http.Request) Decision {
+	d := c.resolve(r).preflight(w, r)
+	if len(c.decisionHooks) > 0 {
+		c.notify(r, d)
+	}
+	return d
+}
+
+// preflight handles a preflight request with the policy of c.
+func (c *Cors) preflight(w http.ResponseWriter, r *http.Request) Decision {
 	headers := w.Header()
 	origin := r.Header.Get("Origin")
 
@@ -338,24 +626,27 @@ func (c *Cors) handlePreflight(w http.ResponseWriter, r *http.Request) {
 	} else {
 		headers["Vary"] = c.preflightVary
 	}
-	allowed, additionalVaryHeaders := c.isOriginAllowed(r, origin)
-	if len(additionalVaryHeaders) > 0 {
-		headers.Add("Vary", strings.Join(convert(additionalVaryHeaders, http.CanonicalHeaderKey), ", "))
+	od := c.evaluateOrigin(r, origin)
+	if len(od.varyHeaders) > 0 {
+		headers.Add("Vary", strings.Join(convert(od.varyHeaders, http.CanonicalHeaderKey), ", "))
 	}
+	reqMethod := r.Header.Get("Access-Control-Request-Method")
+	d := c.decision(true, origin, reqMethod, od)
 
 	if origin == "" {
-		c.logf("  Preflight aborted: empty origin")
-		return
+		c.rlogf(r, "  Preflight aborted: empty origin")
+		return d.reject(ReasonMissingOrigin)
 	}
-	if !allowed {
-		c.logf("  Preflight aborted: origin '%s' not allowed", origin)
-		return
+	if !od.allowed {
+		d = c.rejectOrigin(r, d, od)
+		c.rlogf(r, "  Preflight aborted: origin '%s' %s%s", origin, od.rejection(), logHint(d.Hint))
+		return d
 	}
+	p := od.rule.policy
 
-	reqMethod := r.Header.Get("Access-Control-Request-Method")
-	if !c.isMethodAllowed(reqMethod) {
-		c.logf("  Preflight aborted: method '%s' not allowed", reqMethod)
-		return
+	if !p.isMethodAllowed(reqMethod) {
+		c.rlogf(r, "  Preflight aborted: method '%s' not allowed", reqMethod)
+		return d.reject(ReasonMethod)
 	}
 	// Note: the Fetch standard guarantees that at most one
 	// Access-Control-Request-Headers header is present in the preflight request;
@@ -363,11 +654,11 @@ func (c *Cors) handlePreflight(w http.ResponseWriter, r *http.Request) {
 	// However, some gateways split that header into multiple headers of the same name;
 	// see https://github.com/rs/cors/issues/184.
 	reqHeaders, found := r.Header["Access-Control-Request-Headers"]
-	if found && !c.allowedHeadersAll && !c.allowedHeaders.Accepts(reqHeaders) {
-		c.logf("  Preflight aborted: headers '%v' not allowed", reqHeaders)
-		return
+	if found && !p.allowedHeadersAll && !p.allowedHeaders.Accepts(reqHeaders) {
+		c.rlogf(r, "  Preflight aborted: headers '%v' not allowed", reqHeaders)
+		return d.reject(ReasonHeaders)
 	}
-	if c.allowedOriginsAll {
+	if od.rule.all && !(origin == nullOrigin && c.nullOrigin.EchoOrigin) {
 		headers["Access-Control-Allow-Origin"] = headerOriginAll
 	} else {
 		headers["Access-Control-Allow-Origin"] = r.Header["Origin"]
@@ -380,24 +671,41 @@ func (c *Cors) handlePreflight(w http.ResponseWriter, r *http.Request) {
 		// from Access-Control-Request-Headers can be enough
 		headers["Access-Control-Allow-Headers"] = reqHeaders
 	}
-	if c.allowCredentials {
+	if p.allowCredentials && (origin != nullOrigin || c.nullOrigin.AllowCredentials) {
 		headers["Access-Control-Allow-Credentials"] = headerTrue
 	}
-	if c.allowPrivateNetwork && r.Header.Get("Access-Control-Request-Private-Network") == "true" {
-		headers["Access-Control-Allow-Private-Network"] = headerTrue
+	if r.Header.Get("Access-Control-Request-Private-Network") == "true" {
+		if c.allowPrivateNetwork {
+			headers["Access-Control-Allow-Private-Network"] = headerTrue
+		} else {
+			// Browsers reject the response despite the other headers
+			c.rlogf(r, "  Preflight private network access not allowed")
+			d = d.reject(ReasonPrivateNetwork)
+		}
 	}
-	if len(c.maxAge) > 0 {
-		headers["Access-Control-Max-Age"] = c.maxAge
+	if len(p.maxAge) > 0 {
+		headers["Access-Control-Max-Age"] = p.maxAge
 	}
-	c.logf("  Preflight response headers: %v", headers)
+	c.rlogf(r, "  Preflight response headers: %v", headers)
+	return d
 }
 
 // handleActualRequest handles simple cross-origin requests, actual request or redirects
-func (c *Cors) handleActualRequest(w http.ResponseWriter, r *http.Request) {
+func (c *Cors) handleActualRequest(w http.ResponseWriter, r *http.Request) Decision {
+	d := c.checkCSRF(r, c.resolve(r).actualRequest(w, r))
+	if len(c.decisionHooks) > 0 {
+		c.notify(r, d)
+	}
+	return d
+}
+
+// actualRequest handles an actual request with the policy of c.
+func (c *Cors) actualRequest(w http.ResponseWriter, r *http.Request) Decision {
 	headers := w.Header()
 	origin := r.Header.Get("Origin")
 
-	allowed, additionalVaryHeaders := c.isOriginAllowed(r, origin)
+	od := c.evaluateOrigin(r, origin)
+	d := c.decision(false, origin, r.Method, od)
 
 	// Always set Vary, see https://github.com/rs/cors/issues/10
 	if vary := headers["Vary"]; vary == nil {
@@ -405,38 +713,51 @@ func (c *Cors) handleActualRequest(w http.ResponseWriter, r *http.Request) {
 	} else {
 		headers["Vary"] = append(vary, headerVaryOrigin[0])
 	}
-	if len(additionalVaryHeaders) > 0 {
-		headers.Add("Vary", strings.Join(convert(additionalVaryHeaders, http.CanonicalHeaderKey), ", "))
+	if len(od.varyHeaders) > 0 {
+		headers.Add("Vary", strings.Join(convert(od.varyHeaders, http.CanonicalHeaderKey), ", "))
 	}
 	if origin == "" {
-		c.logf("  Actual request no headers added: missing origin")
-		return
+		c.rlogf(r, "  Actual request no headers added: missing origin")
+		return d.reject(ReasonMissingOrigin)
 	}
-	if !allowed {
-		c.logf("  Actual request no headers added: origin '%s' not allowed", origin)
-		return
+	if !od.allowed {
+		d = c.rejectOrigin(r, d, od)
+		c.rlogf(r, "  Actual request no headers added: origin '%s' %s%s", origin, od.rejection(), logHint(d.Hint))
+		return d
 	}
+	p := od.rule.policy
 
 	// Note that spec does define a way to specifically disallow a simple method like GET or
 	// POST. Access-Control-Allow-Methods is only used for pre-flight requests and the
 	// spec doesn't instruct to check the allowed methods for simple cross-origin requests.
 	// We think it's a nice feature to be able to have control on those methods though.
-	if !c.isMethodAllowed(r.Method) {
-		c.logf("  Actual request no headers added: method '%s' not allowed", r.Method)
-		return
+	if !p.isMethodAllowed(r.Method) {
+		c.rlogf(r, "  Actual request no headers added: method '%s' not allowed", r.Method)
+		return d.reject(ReasonMethod)
 	}
-	if c.allowedOriginsAll {
+	if od.rule.all && !(origin == nullOrigin && c.nullOrigin.EchoOrigin) {
 		headers["Access-Control-Allow-Origin"] = headerOriginAll
 	} else {
 		headers["Access-Control-Allow-Origin"] = r.Header["Origin"]
 	}
-	if len(c.exposedHeaders) > 0 {
-		headers["Access-Control-Expose-Headers"] = c.exposedHeaders
+	if len(p.exposedHeaders) > 0 {
+		headers["Access-Control-Expose-Headers"] = p.exposedHeaders
 	}
-	if c.allowCredentials {
+	if p.allowCredentials && (origin != nullOrigin || c.nullOrigin.AllowCredentials) {
 		headers["Access-Control-Allow-Credentials"] = headerTrue
 	}
-	c.logf("  Actual response added headers: %v", headers)
+	c.rlogf(r, "  Actual response added headers: %v", headers)
+	return d
+}
+
+// warnf reports configuration issues. Contrary to logf, it falls back to the
+// standard logger when no logger is set, as such issues must not go unnoticed.
+func (c *Cors) warnf(format string, a ...any) {
+	if c.Log != nil {
+		c.Log.Printf(format, a...)
+		return
+	}
+	log.Printf("[cors] "+format, a...)
 }
 
 // convenience method. checks if a logger is set.
@@ -446,8 +767,22 @@ func (c *Cors) logf(format string, a ...any) {
 	}
 }
 
+// rlogf logs a message about a request, subject to log sampling.
+func (c *Cors) rlogf(r *http.Request, format string, a ...any) {
+	if c.Log == nil {
+		return
+	}
+	if !c.logged(r) {
+		return
+	}
+	c.Log.Printf(format, a...)
+}
+
 // check the Origin of a request. No origin at all is also allowed.
 func (c *Cors) OriginAllowed(r *http.Request) bool {
+	if rc := c.resolve(r); rc != c {
+		return rc.OriginAllowed(r)
+	}
 	origin := r.Header.Get("Origin")
 	allowed, _ := c.isOriginAllowed(r, origin)
 	return allowed
@@ -456,31 +791,20 @@ func (c *Cors) OriginAllowed(r *http.Request) bool {
 // isOriginAllowed checks if a given origin is allowed to perform cross-domain requests
 // on the endpoint
 func (c *Cors) isOriginAllowed(r *http.Request, origin string) (allowed bool, varyHeaders []string) {
+	d := c.evaluateOrigin(r, origin)
+	return d.allowed, d.varyHeaders
+}
+
+// matchOrigin checks if a given origin is allowed by the origin options.
+func (c *Cors) matchOrigin(r *http.Request, origin string) (allowed bool, varyHeaders []string) {
+	if origin == nullOrigin && c.allowNullOrigin {
+		return true, nil
+	}
 	if c.allowOriginFunc != nil {
 		return c.allowOriginFunc(r, origin)
 	}
 	if c.allowedOriginsAll {
-		return true, nil
-	}
-	origin = strings.ToLower(origin)
-	if slices.Contains(c.allowedOrigins, origin) {
-		return true, nil
-	}
-	return slices.ContainsFunc(c.allowedWOrigins, func(w wildcard) bool {
-		return w.match(origin)
-	}), nil
-}
-
-// isMethodAllowed checks if a given method can be used as part of a cross-domain request
-// on the endpoint
-func (c *Cors) isMethodAllowed(method string) bool {
-	if len(c.allowedMethods) == 0 {
-		// If no method allowed, always return false, even for preflight request
-		return false
-	}
-	if method == http.MethodOptions {
-		// Always allow preflight requests
-		return true
+		return origin != nullOrigin || !c.nullOrigin.ExcludeFromAllowAll, nil
 	}
-	return slices.Contains(c.allowedMethods, method)
+	return c.allowedOrigins.contains(origin), nil
 }
@@ -2,6 +2,7 @@ package cors
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -179,6 +180,358 @@ func TestSpec(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"PortWildcardOrigin",
+			Options{
+				AllowedOrigins: []string{"http://localhost:*"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"http://localhost:3000"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"http://localhost:3000"},
+			},
+			true,
+		},
+		{
+			"DisallowedPortWildcardOrigin",
+			Options{
+				AllowedOrigins: []string{"http://localhost:*"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"http://localhost:evil"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+			false,
+		},
+		{
+			"PortRangeOrigin",
+			Options{
+				AllowedOrigins: []string{"http://127.0.0.1:3000-3999"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"http://127.0.0.1:3456"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"http://127.0.0.1:3456"},
+			},
+			true,
+		},
+		{
+			"DisallowedPortRangeOrigin",
+			Options{
+				AllowedOrigins: []string{"http://127.0.0.1:3000-3999"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"http://127.0.0.1:8080"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+			false,
+		},
+		{
+			"DefaultPortOrigin",
+			Options{
+				AllowedOrigins: []string{"https://foobar.com:443"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"https://foobar.com"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"https://foobar.com"},
+			},
+			true,
+		},
+		{
+			"IDNOrigin",
+			Options{
+				AllowedOrigins: []string{"https://bücher.de"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"https://xn--bcher-kva.de"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"https://xn--bcher-kva.de"},
+			},
+			true,
+		},
+		{
+			"IPv6Origin",
+			Options{
+				AllowedOrigins: []string{"http://[0:0:0:0:0:0:0:1]:8080"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"http://[::1]:8080"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"http://[::1]:8080"},
+			},
+			true,
+		},
+		{
+			"HostOnlyOrigin",
+			Options{
+				AllowedOrigins: []string{"foobar.com"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"https://foobar.com"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"https://foobar.com"},
+			},
+			true,
+		},
+		{
+			"SchemeRelativeOrigin",
+			Options{
+				AllowedOrigins: []string{"//foobar.com:8443"},
+				DefaultSchemes: []string{"https"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"http://foobar.com:8443"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+			false,
+		},
+		{
+			"CIDROrigin",
+			Options{
+				AllowedOrigins: []string{"http://10.0.0.0/8:*"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"http://10.2.3.4:8080"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"http://10.2.3.4:8080"},
+			},
+			true,
+		},
+		{
+			"CIDROriginHostname",
+			Options{
+				AllowedOrigins: []string{"http://10.0.0.0/8:*"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"http://10.example.com:8080"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+			false,
+		},
+		{
+			"NullOriginMatchAll",
+			Options{
+				AllowedOrigins: []string{"*"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"null"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"*"},
+			},
+			true,
+		},
+		{
+			"NullOriginExcludedFromMatchAll",
+			Options{
+				AllowedOrigins:   []string{"*"},
+				NullOriginPolicy: NullOriginPolicy{ExcludeFromAllowAll: true},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"null"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+			false,
+		},
+		{
+			"NullOriginEcho",
+			Options{
+				AllowedOrigins:   []string{"*"},
+				NullOriginPolicy: NullOriginPolicy{EchoOrigin: true},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"null"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"null"},
+			},
+			true,
+		},
+		{
+			"DisallowedNullOrigin",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"null"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+			false,
+		},
+		{
+			"AllowedNullOrigin",
+			Options{
+				AllowedOrigins:   []string{"http://foobar.com"},
+				AllowNullOrigin:  true,
+				AllowCredentials: true,
+			},
+			"GET",
+			http.Header{
+				"Origin": {"null"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"null"},
+			},
+			true,
+		},
+		{
+			"AllowedNullOriginWithCredentials",
+			Options{
+				AllowedOrigins:   []string{"http://foobar.com"},
+				AllowNullOrigin:  true,
+				AllowCredentials: true,
+				NullOriginPolicy: NullOriginPolicy{AllowCredentials: true},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"null"},
+			},
+			http.Header{
+				"Vary":                             {"Origin"},
+				"Access-Control-Allow-Origin":      {"null"},
+				"Access-Control-Allow-Credentials": {"true"},
+			},
+			true,
+		},
+		{
+			"NullInAllowedOrigins",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com", "null"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"null"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"null"},
+			},
+			true,
+		},
+		{
+			"NullInAllowedOriginsWithCredentials",
+			Options{
+				AllowedOrigins:   []string{"http://foobar.com", "null"},
+				AllowCredentials: true,
+			},
+			"GET",
+			http.Header{
+				"Origin": {"null"},
+			},
+			http.Header{
+				"Vary":                             {"Origin"},
+				"Access-Control-Allow-Origin":      {"null"},
+				"Access-Control-Allow-Credentials": {"true"},
+			},
+			true,
+		},
+		{
+			"DeniedOrigin",
+			Options{
+				AllowedOrigins: []string{"https://*.foobar.com"},
+				DeniedOrigins:  []string{"https://sandbox.foobar.com"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"https://sandbox.foobar.com"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+			false,
+		},
+		{
+			"NotDeniedOrigin",
+			Options{
+				AllowedOrigins: []string{"https://*.foobar.com"},
+				DeniedOrigins:  []string{"https://sandbox.foobar.com"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"https://www.foobar.com"},
+			},
+			http.Header{
+				"Vary":                        {"Origin"},
+				"Access-Control-Allow-Origin": {"https://www.foobar.com"},
+			},
+			true,
+		},
+		{
+			"DeniedOriginMatchAll",
+			Options{
+				DeniedOrigins: []string{"*.usercontent.foobar.com"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"https://abc.usercontent.foobar.com"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+			false,
+		},
+		{
+			"DeniedOriginBeforeAllowOriginFunc",
+			Options{
+				AllowOriginFunc: func(origin string) bool {
+					return true
+				},
+				DeniedOrigins: []string{"https://sandbox.foobar.com"},
+			},
+			"GET",
+			http.Header{
+				"Origin": {"https://sandbox.foobar.com"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+			false,
+		},
 		{
 			"AllowedOriginFuncMatch",
 			Options{
@@ -635,6 +988,86 @@ func TestLogger(t *testing.T) {
 	}
 }
 
+func TestValidate(t *testing.T) {
+	err := Options{
+		AllowedOrigins: []string{"*", "foo.com", "//foo.com:8443", "foo.com:8443", "http://foo.com/bar"},
+		DeniedOrigins:  []string{"*"},
+	}.Validate()
+	if err == nil {
+		t.Fatal("Validate should report invalid origins")
+	}
+	var oerr *OriginError
+	if !errors.As(err, &oerr) || oerr.Origin != "foo.com:8443" {
+		t.Errorf("Validate error = %v, want an *OriginError for foo.com:8443", err)
+	}
+	if got := strings.Count(err.Error(), "invalid origin"); got != 3 {
+		t.Errorf("Validate reported %d invalid origins, want 3: %v", got, err)
+	}
+	if err := (Options{AllowedOrigins: []string{"foo.com"}}).Validate(); err != nil {
+		t.Errorf("Validate unexpected error: %v", err)
+	}
+}
+
+func TestDeniedOriginsMatchAll(t *testing.T) {
+	logger := &testLogger{buf: &bytes.Buffer{}}
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		DeniedOrigins:  []string{"*", "https://bar.com"},
+		Logger:         logger,
+	})
+	if !strings.Contains(logger.buf.String(), `invalid origin "*"`) {
+		t.Errorf("New should warn about \"*\" in DeniedOrigins, got %q", logger.buf.String())
+	}
+	if allowed, _ := s.isOriginAllowed(nil, "https://foo.com"); !allowed {
+		t.Error("\"*\" in DeniedOrigins should be ignored")
+	}
+	if allowed, _ := s.isOriginAllowed(nil, "https://bar.com"); allowed {
+		t.Error("the other DeniedOrigins should still apply")
+	}
+}
+
+func TestInvalidOriginWarning(t *testing.T) {
+	logger := &testLogger{buf: &bytes.Buffer{}}
+	s := New(Options{
+		AllowedOrigins: []string{"foo.com:8443", "http://bar.com"},
+		Logger:         logger,
+	})
+	if !strings.Contains(logger.buf.String(), `invalid origin "foo.com:8443"`) {
+		t.Errorf("New should warn about invalid origins, got %q", logger.buf.String())
+	}
+	if !s.allowedOrigins.contains("http://bar.com") {
+		t.Error("New should keep valid origins")
+	}
+}
+
+func TestNullOriginWarning(t *testing.T) {
+	logger := &testLogger{buf: &bytes.Buffer{}}
+	New(Options{
+		AllowNullOrigin:  true,
+		AllowCredentials: true,
+		NullOriginPolicy: NullOriginPolicy{AllowCredentials: true},
+		Logger:           logger,
+	})
+	if !strings.Contains(logger.buf.String(), `the "null" origin is allowed with credentials`) {
+		t.Errorf("New should warn when the null origin is allowed, got %q", logger.buf.String())
+	}
+}
+
+func TestDeniedOriginLog(t *testing.T) {
+	logger := &testLogger{buf: &bytes.Buffer{}}
+	s := New(Options{
+		AllowedOrigins: []string{"https://*.foobar.com"},
+		DeniedOrigins:  []string{"https://sandbox.foobar.com"},
+		Logger:         logger,
+	})
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://sandbox.foobar.com")
+	s.handleActualRequest(httptest.NewRecorder(), req)
+	if !strings.Contains(logger.buf.String(), "denied by rule 'DeniedOrigins'") {
+		t.Errorf("denied origin should be logged as such, got %q", logger.buf.String())
+	}
+}
+
 func TestDefault(t *testing.T) {
 	s := Default()
 	if s.Log != nil {
@@ -0,0 +1,95 @@
+//go:build go1.25
+
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// CrossOriginProtection returns an http.CrossOriginProtection trusting the
+// origins allowed by c, bypassing the patterns of its CSRFProtection and
+// rejecting requests with its RejectionStatus, so the allowlist of c doesn't
+// have to be kept in sync with AddTrustedOrigin by hand.
+//
+// An http.CrossOriginProtection only trusts exact origins. The returned error
+// lists the origins allowed by c which it can't trust: wildcards, port
+// wildcards and ranges, IP prefixes, all origins, the null origin and the
+// origins allowed by AllowOriginFunc (and its variants), an OriginStore or a
+// PolicyResolver. The requests from these origins are rejected by the
+// returned protection, which errs on the side of safety; ProtectedHandler
+// trusts them. Exact origins denied by DeniedOrigins or a preceding rule are
+// not trusted.
+func (c *Cors) CrossOriginProtection() (*http.CrossOriginProtection, error) {
+	cop := http.NewCrossOriginProtection()
+	var errs []error
+	untrusted := func(origin, reason string) {
+		errs = append(errs, fmt.Errorf("cors: origin %q can't be trusted by the cross-origin protection: %s", origin, reason))
+	}
+	if c.policyResolver != nil {
+		errs = append(errs, errors.New("cors: the origins of the policies returned by PolicyResolver can't be trusted by the cross-origin protection"))
+	}
+	if c.allowNullOrigin {
+		untrusted(nullOrigin, "opaque origin")
+	}
+	r := &http.Request{Header: http.Header{}}
+	for _, rl := range c.rules {
+		switch {
+		case rl.deny:
+			continue
+		case rl.all:
+			untrusted("*", "all origins are allowed by "+rl.String())
+			continue
+		case rl.origins == nil:
+			errs = append(errs, errors.New("cors: the origins allowed by a function or an OriginStore can't be trusted by the cross-origin protection"))
+			continue
+		}
+		for _, origin := range rl.origins.exact() {
+			if od := c.evaluateOrigin(r, origin); !od.allowed {
+				continue
+			}
+			if err := cop.AddTrustedOrigin(origin); err != nil {
+				untrusted(origin, err.Error())
+			}
+		}
+		for _, pattern := range rl.origins.patterns() {
+			untrusted(pattern, "only exact origins can be trusted")
+		}
+	}
+
+	if c.csrf != nil {
+		for _, pattern := range c.csrf.patterns {
+			cop.AddInsecureBypassPattern(pattern)
+		}
+	}
+	status := c.rejectionStatus()
+	cop.SetDenyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rejectCrossSite(w, status)
+	}))
+	return cop, errors.Join(errs...)
+}
+
+// ProtectedHandler applies the CORS specification like Handler, then checks
+// the actual requests with the http.CrossOriginProtection returned by
+// CrossOriginProtection, which replaces the CSRFProtection of the options.
+// The requests allowed by the CORS policy are trusted, including the ones
+// from origins the http.CrossOriginProtection can't trust, like wildcards.
+// Without CSRFProtection.RejectSameSite, same-site requests are checked like
+// with CSRFProtection instead: their Origin must be allowed or have the host
+// of the request.
+// Preflight requests are handled by CORS alone. The origins which can't be
+// trusted by the http.CrossOriginProtection are logged.
+func (c *Cors) ProtectedHandler(h http.Handler) http.Handler {
+	cop, err := c.CrossOriginProtection()
+	if err != nil {
+		c.logf("%v", err)
+	}
+	// Shallow copy sharing the compiled policy of c
+	pc := *c
+	pc.csrf = &csrfProtection{status: c.rejectionStatus(), rejectSameSite: c.rejectSameSite(), check: cop.Check}
+	if c.csrf != nil {
+		pc.csrf.bypass = c.csrf.bypass
+	}
+	return pc.Handler(h)
+}
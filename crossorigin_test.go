@@ -0,0 +1,219 @@
+//go:build go1.25
+
+package cors
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCrossOriginProtection(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com", "https://*.bar.com", "http://localhost:3000-3999"},
+		DeniedOrigins:  []string{"https://evil.com"},
+		Rules: []Rule{
+			{Name: "evil", Origins: []string{"https://evil.com"}},
+		},
+		OriginGroups: []OriginGroup{
+			{Name: "partners", Origins: []string{"https://acme.com"}},
+		},
+		CSRFProtection: CSRFProtection{
+			Enabled:         true,
+			BypassPatterns:  []string{"POST /webhooks/"},
+			RejectionStatus: http.StatusUnprocessableEntity,
+		},
+	})
+	cop, err := s.CrossOriginProtection()
+	if err == nil {
+		t.Fatal("CrossOriginProtection should report the patterns it can't trust")
+	}
+	for _, pattern := range []string{`"https://*.bar.com"`, `"http://localhost:3000-3999"`} {
+		if !strings.Contains(err.Error(), pattern) {
+			t.Errorf("error %q should mention %s", err, pattern)
+		}
+	}
+
+	cases := []struct {
+		origin string
+		path   string
+		ok     bool
+	}{
+		{"https://foo.com", "/", true},
+		{"https://acme.com", "/", true},
+		{"https://evil.com", "/", false},
+		{"https://www.bar.com", "/", false},
+		{"https://baz.com", "/", false},
+		{"https://baz.com", "/webhooks/github", true},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodPost, "http://example.com"+tc.path, nil)
+		req.Header.Set("Origin", tc.origin)
+		req.Header.Set("Sec-Fetch-Site", "cross-site")
+		if err := cop.Check(req); (err == nil) != tc.ok {
+			t.Errorf("origin %q on %s: got error %v, want ok = %t", tc.origin, tc.path, err, tc.ok)
+		}
+	}
+
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/", nil)
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	cop.Handler(testHandler).ServeHTTP(res, req)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d, want %d", res.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestCrossOriginProtectionUntrusted(t *testing.T) {
+	cases := []struct {
+		name    string
+		options Options
+	}{
+		{"AllOrigins", Options{}},
+		{"NullOrigin", Options{AllowedOrigins: []string{"https://foo.com"}, AllowNullOrigin: true}},
+		{"Func", Options{AllowOriginFunc: func(string) bool { return true }}},
+		{"Resolver", Options{PolicyResolver: func(*http.Request) (*Cors, error) { return nil, nil }}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.options.Logger = &testLogger{buf: &bytes.Buffer{}}
+			if _, err := New(tc.options).CrossOriginProtection(); err == nil {
+				t.Error("CrossOriginProtection should report the origins it can't trust")
+			}
+		})
+	}
+}
+
+// TestCrossOriginProtectionAgrees checks that the CSRFProtection rejecting
+// same-site requests and the http.CrossOriginProtection built from the same
+// policy of exact origins take the same decisions.
+func TestCrossOriginProtectionAgrees(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com", "http://localhost:3000"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		CSRFProtection: CSRFProtection{
+			Enabled:        true,
+			BypassPatterns: []string{"POST /webhooks/"},
+			RejectSameSite: true,
+		},
+	})
+	cop, err := s.CrossOriginProtection()
+	if err != nil {
+		t.Fatalf("CrossOriginProtection failed: %v", err)
+	}
+	h := s.Handler(testHandler)
+
+	origins := []string{"", "https://foo.com", "http://localhost:3000", "https://bar.com", "http://example.com", "null"}
+	sites := []string{"", "cross-site", "same-site", "same-origin", "none"}
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+	paths := []string{"/", "/webhooks/github"}
+	for _, origin := range origins {
+		for _, site := range sites {
+			for _, method := range methods {
+				for _, path := range paths {
+					req, _ := http.NewRequest(method, "http://example.com"+path, nil)
+					if origin != "" {
+						req.Header.Set("Origin", origin)
+					}
+					if site != "" {
+						req.Header.Set("Sec-Fetch-Site", site)
+					}
+					res := httptest.NewRecorder()
+					h.ServeHTTP(res, req)
+					blocked := res.Code == http.StatusForbidden
+					if want := cop.Check(req) != nil; blocked != want {
+						t.Errorf("%s %s from %q (Sec-Fetch-Site: %q): blocked = %t, http.CrossOriginProtection blocked = %t",
+							method, path, origin, site, blocked, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestProtectedHandler(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com", "https://*.bar.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut},
+	})
+	h := s.ProtectedHandler(testHandler)
+
+	cases := []struct {
+		name       string
+		method     string
+		reqHeaders http.Header
+		status     int
+		resHeaders http.Header
+	}{
+		{
+			"ExactOrigin",
+			http.MethodPost,
+			http.Header{"Origin": {"https://foo.com"}, "Sec-Fetch-Site": {"cross-site"}},
+			http.StatusOK,
+			http.Header{"Vary": {"Origin"}, "Access-Control-Allow-Origin": {"https://foo.com"}},
+		},
+		{
+			"WildcardOrigin",
+			http.MethodPut,
+			http.Header{"Origin": {"https://www.bar.com"}, "Sec-Fetch-Site": {"cross-site"}},
+			http.StatusOK,
+			http.Header{"Vary": {"Origin"}, "Access-Control-Allow-Origin": {"https://www.bar.com"}},
+		},
+		{
+			"NotAllowed",
+			http.MethodPost,
+			http.Header{"Origin": {"https://baz.com"}, "Sec-Fetch-Site": {"cross-site"}},
+			http.StatusForbidden,
+			http.Header{"Vary": {"Origin"}},
+		},
+		{
+			"SameOrigin",
+			http.MethodPost,
+			http.Header{"Origin": {"http://example.com"}, "Sec-Fetch-Site": {"same-origin"}},
+			http.StatusOK,
+			http.Header{"Vary": {"Origin"}},
+		},
+		{
+			"SameSite",
+			http.MethodPost,
+			http.Header{"Origin": {"https://api.example.com"}, "Sec-Fetch-Site": {"same-site"}},
+			http.StatusForbidden,
+			http.Header{"Vary": {"Origin"}},
+		},
+		{
+			"Preflight",
+			http.MethodOptions,
+			http.Header{"Origin": {"https://www.bar.com"}, "Access-Control-Request-Method": {http.MethodPut}},
+			http.StatusNoContent,
+			http.Header{
+				"Vary":                         {"Origin, Access-Control-Request-Method, Access-Control-Request-Headers"},
+				"Access-Control-Allow-Origin":  {"https://www.bar.com"},
+				"Access-Control-Allow-Methods": {http.MethodPut},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+			req.Header = tc.reqHeaders
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+			if res.Code != tc.status {
+				t.Errorf("got status %d, want %d", res.Code, tc.status)
+			}
+			assertHeaders(t, res.Header(), tc.resHeaders)
+		})
+	}
+
+	// The protection doesn't leak to the handlers of s
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/foo", nil)
+	req.Header.Set("Origin", "https://baz.com")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("Handler got status %d, want %d", res.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,169 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// CSRFProtection rejects cross-site state-changing requests, which CORS alone
+// lets through: a cross-origin form POST reaches the handler, only its
+// response is hidden from the page. The origins allowed by the CORS policy
+// are trusted, so a single allowlist covers both concerns.
+//
+// Requests using a safe method (GET, HEAD or OPTIONS) are never rejected.
+// Other requests are rejected when they are not allowed by the CORS policy
+// and either carry a "cross-site" Sec-Fetch-Site header, or a "same-site" or
+// no Sec-Fetch-Site header and an Origin header whose host differs from the
+// host of the request. Requests with neither header are not made by a
+// browser and are accepted. The protection is of little use when the policy
+// allows all origins, as they are all trusted.
+type CSRFProtection struct {
+	// Enabled turns the protection on.
+	Enabled bool
+	// BypassPatterns lists the requests exempted from the protection, using
+	// the pattern syntax of http.ServeMux (i.e.: "POST /webhooks/" or
+	// "/oauth/callback"). Invalid patterns are ignored; New logs them.
+	BypassPatterns []string
+	// RejectionStatus is the status code of the responses to the rejected
+	// requests.
+	// Default value is http.StatusForbidden (403).
+	RejectionStatus int
+	// RejectSameSite rejects all the requests carrying a "same-site"
+	// Sec-Fetch-Site header, which come from another origin of the same
+	// site (i.e.: a sibling subdomain), like http.CrossOriginProtection does.
+	// Otherwise, they are rejected when their Origin is not allowed and has
+	// another host than the request.
+	RejectSameSite bool
+}
+
+// csrfProtection enforces a CSRFProtection.
+type csrfProtection struct {
+	bypass *http.ServeMux
+	// Valid bypass patterns
+	patterns       []string
+	status         int
+	rejectSameSite bool
+	// Optional check replacing the one of the CSRFProtection, i.e.: the one
+	// of an http.CrossOriginProtection, which handles the bypass patterns
+	check func(r *http.Request) error
+}
+
+// newCSRFProtection compiles p, returning nil when it is disabled, and the
+// errors of its invalid bypass patterns.
+func newCSRFProtection(p CSRFProtection) (*csrfProtection, []error) {
+	if !p.Enabled {
+		return nil, nil
+	}
+	cp := &csrfProtection{status: p.RejectionStatus, rejectSameSite: p.RejectSameSite}
+	if cp.status == 0 {
+		cp.status = http.StatusForbidden
+	}
+	var errs []error
+	if len(p.BypassPatterns) > 0 {
+		cp.bypass = http.NewServeMux()
+		for _, pattern := range p.BypassPatterns {
+			if err := addPattern(cp.bypass, pattern); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			cp.patterns = append(cp.patterns, pattern)
+		}
+	}
+	return cp, errs
+}
+
+// addPattern registers pattern on mux, reporting invalid or conflicting
+// patterns as errors rather than panics.
+func addPattern(mux *http.ServeMux, pattern string) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("cors: invalid CSRF bypass pattern %q: %v", pattern, e)
+		}
+	}()
+	mux.Handle(pattern, http.NotFoundHandler())
+	return nil
+}
+
+// bypassed reports whether r matches a bypass pattern.
+func (cp *csrfProtection) bypassed(r *http.Request) bool {
+	if cp.bypass == nil {
+		return false
+	}
+	_, pattern := cp.bypass.Handler(r)
+	return pattern != ""
+}
+
+// crossSite reports whether r is a cross-site request to reject given the
+// Decision d taken by the CORS policy.
+func (cp *csrfProtection) crossSite(r *http.Request, d Decision) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	if d.Allowed {
+		return false
+	}
+	site := r.Header.Get("Sec-Fetch-Site")
+	// Without RejectSameSite, same-site requests are checked like the ones
+	// without Sec-Fetch-Site, so a sibling subdomain is trusted only when
+	// its origin is allowed
+	sameSite := site == "same-site" && !cp.rejectSameSite
+	if cp.check != nil && !sameSite {
+		return cp.check(r) != nil
+	}
+	if cp.bypassed(r) {
+		return false
+	}
+	switch site {
+	case "":
+	case "cross-site":
+		return true
+	case "same-site":
+		if cp.rejectSameSite {
+			return true
+		}
+	default:
+		return false
+	}
+	if d.Origin == "" {
+		return false
+	}
+	o, err := url.Parse(d.Origin)
+	return err != nil || o.Host != r.Host
+}
+
+// checkCSRF marks d as blocked when the CSRF protection rejects r.
+func (c *Cors) checkCSRF(r *http.Request, d Decision) Decision {
+	if c.csrf == nil || !c.csrf.crossSite(r, d) {
+		return d
+	}
+	c.rlogf(r, "  Actual request blocked: cross-site %s request from origin '%s'", r.Method, d.Origin)
+	d.Blocked = true
+	return d
+}
+
+// rejectCSRF responds to a request blocked by the CSRF protection.
+func (c *Cors) rejectCSRF(w http.ResponseWriter) {
+	rejectCrossSite(w, c.csrf.status)
+}
+
+// rejectionStatus returns the status of the responses to the requests
+// rejected as cross-site.
+func (c *Cors) rejectionStatus() int {
+	if c.csrf == nil {
+		return http.StatusForbidden
+	}
+	return c.csrf.status
+}
+
+// rejectSameSite reports whether the CSRF protection rejects same-site
+// requests.
+func (c *Cors) rejectSameSite() bool {
+	return c.csrf != nil && c.csrf.rejectSameSite
+}
+
+// rejectCrossSite responds to a rejected cross-site request with status.
+func rejectCrossSite(w http.ResponseWriter, status int) {
+	http.Error(w, "cross-site request rejected", status)
+}
@@ -0,0 +1,136 @@
+package cors
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSRFProtection(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		CSRFProtection: CSRFProtection{
+			Enabled:        true,
+			BypassPatterns: []string{"POST /webhooks/", "/callback"},
+		},
+	})
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		reqHeaders http.Header
+		blocked    bool
+	}{
+		{"AllowedOrigin", http.MethodPost, "/", http.Header{"Origin": {"https://foo.com"}, "Sec-Fetch-Site": {"cross-site"}}, false},
+		{"CrossSite", http.MethodPost, "/", http.Header{"Origin": {"https://bar.com"}, "Sec-Fetch-Site": {"cross-site"}}, true},
+		{"CrossSiteWithoutOrigin", http.MethodPost, "/", http.Header{"Sec-Fetch-Site": {"cross-site"}}, true},
+		{"SameSite", http.MethodPost, "/", http.Header{"Origin": {"https://api.example.com"}, "Sec-Fetch-Site": {"same-site"}}, true},
+		{"SameSiteAllowedOrigin", http.MethodPost, "/", http.Header{"Origin": {"https://foo.com"}, "Sec-Fetch-Site": {"same-site"}}, false},
+		{"SameSiteSameHost", http.MethodPost, "/", http.Header{"Origin": {"https://example.com"}, "Sec-Fetch-Site": {"same-site"}}, false},
+		{"SameSiteBypass", http.MethodPost, "/webhooks/github", http.Header{"Origin": {"https://api.example.com"}, "Sec-Fetch-Site": {"same-site"}}, false},
+		{"SameOrigin", http.MethodPost, "/", http.Header{"Origin": {"http://example.com"}, "Sec-Fetch-Site": {"same-origin"}}, false},
+		{"UserInitiated", http.MethodPost, "/", http.Header{"Sec-Fetch-Site": {"none"}}, false},
+		{"OriginNotAllowed", http.MethodPut, "/", http.Header{"Origin": {"https://bar.com"}}, true},
+		{"NullOrigin", http.MethodPost, "/", http.Header{"Origin": {"null"}}, true},
+		{"OriginSameHost", http.MethodPost, "/", http.Header{"Origin": {"http://example.com"}}, false},
+		{"NoBrowserHeaders", http.MethodDelete, "/", http.Header{}, false},
+		{"SafeMethod", http.MethodGet, "/", http.Header{"Origin": {"https://bar.com"}, "Sec-Fetch-Site": {"cross-site"}}, false},
+		{"Head", http.MethodHead, "/", http.Header{"Origin": {"https://bar.com"}, "Sec-Fetch-Site": {"cross-site"}}, false},
+		{"Bypass", http.MethodPost, "/webhooks/github", http.Header{"Origin": {"https://bar.com"}, "Sec-Fetch-Site": {"cross-site"}}, false},
+		{"BypassOtherMethod", http.MethodPut, "/webhooks/github", http.Header{"Origin": {"https://bar.com"}, "Sec-Fetch-Site": {"cross-site"}}, true},
+		{"BypassAnyMethod", http.MethodPut, "/callback", http.Header{"Origin": {"https://bar.com"}}, false},
+	}
+	handlers := map[string]http.Handler{
+		"Handler": s.Handler(testHandler),
+		"ServeHTTP": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.ServeHTTP(w, r, testHandler)
+		}),
+	}
+	for hname, h := range handlers {
+		for _, tc := range cases {
+			t.Run(hname+"/"+tc.name, func(t *testing.T) {
+				req, _ := http.NewRequest(tc.method, "http://example.com"+tc.path, nil)
+				req.Header = tc.reqHeaders
+				res := httptest.NewRecorder()
+				h.ServeHTTP(res, req)
+				if blocked := res.Code == http.StatusForbidden; blocked != tc.blocked {
+					t.Errorf("blocked = %t, want %t (status %d)", blocked, tc.blocked, res.Code)
+				}
+				if !tc.blocked && res.Body.String() != string(testResponse) {
+					t.Errorf("next handler not called, got body %q", res.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestCSRFProtectionRejectSameSite(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		CSRFProtection: CSRFProtection{Enabled: true, RejectSameSite: true},
+	})
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/", nil)
+	req.Header.Set("Origin", "https://api.example.com")
+	req.Header.Set("Sec-Fetch-Site", "same-site")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if res.Code != http.StatusForbidden {
+		t.Errorf("got status %d, want %d", res.Code, http.StatusForbidden)
+	}
+}
+
+func TestCSRFProtectionDisabled(t *testing.T) {
+	s := New(Options{AllowedOrigins: []string{"https://foo.com"}})
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/", nil)
+	req.Header.Set("Origin", "https://bar.com")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("got status %d without CSRF protection, want %d", res.Code, http.StatusOK)
+	}
+}
+
+func TestCSRFProtectionDecision(t *testing.T) {
+	var got Decision
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		CSRFProtection: CSRFProtection{Enabled: true, RejectionStatus: http.StatusUnprocessableEntity},
+		DecisionHooks: []DecisionHook{DecisionHookFunc(func(r *http.Request, d Decision) {
+			got = d
+		})},
+	})
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/", nil)
+	req.Header.Set("Origin", "https://bar.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if res.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d, want %d", res.Code, http.StatusUnprocessableEntity)
+	}
+	want := Decision{Origin: "https://bar.com", Method: http.MethodPost, Reason: ReasonOrigin, Blocked: true}
+	if got != want {
+		t.Errorf("got decision %+v, want %+v", got, want)
+	}
+}
+
+func TestCSRFProtectionInvalidPattern(t *testing.T) {
+	logger := &testLogger{buf: &bytes.Buffer{}}
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		CSRFProtection: CSRFProtection{Enabled: true, BypassPatterns: []string{"/a/{", "/b/"}},
+		Logger:         logger,
+	})
+	if !strings.Contains(logger.buf.String(), `invalid CSRF bypass pattern "/a/{"`) {
+		t.Errorf("New should log the invalid pattern, got %q", logger.buf.String())
+	}
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/b/c", nil)
+	req.Header.Set("Origin", "https://bar.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("valid patterns should still apply, got status %d", res.Code)
+	}
+}
@@ -0,0 +1,145 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+)
+
+// Reasons of the rejected Decisions.
+const (
+	// ReasonMissingOrigin rejects requests without an Origin header.
+	ReasonMissingOrigin = "missing origin"
+	// ReasonOrigin rejects requests from origins not allowed or denied.
+	ReasonOrigin = "origin"
+	// ReasonMethod rejects requests using a method not allowed.
+	ReasonMethod = "method"
+	// ReasonHeaders rejects preflight requests asking for headers not
+	// allowed.
+	ReasonHeaders = "headers"
+	// ReasonPrivateNetwork rejects preflight requests asking for private
+	// network access when it is not allowed. Such requests get the other
+	// CORS response headers, but browsers reject them.
+	ReasonPrivateNetwork = "private network"
+)
+
+// A Decision describes how a CORS request was handled.
+type Decision struct {
+	// Preflight is true for preflight requests.
+	Preflight bool
+	// Origin of the request.
+	Origin string
+	// Method of the request, or the one requested by a preflight request.
+	Method string
+	// Allowed is true when the request was allowed.
+	Allowed bool
+	// Reason is why the request was rejected, one of the Reason constants.
+	Reason string
+	// Rule is the name of the rule or origin group which matched the
+	// origin, "DeniedOrigins" for denied origins, and "Options" for the
+	// origins allowed by the options themselves. It is empty when no rule
+	// matched.
+	Rule string
+	// Policy is the name of the policy which handled the request; see
+	// Options.Name.
+	Policy string
+	// Hint names, for origins rejected because they are not allowed, the
+	// nearest allowed origin and what differs, e.g. "did you mean
+	// 'https://foo.com' (scheme differs)?". It is empty when no allowed
+	// origin is near, and is only computed when the request is logged or
+	// Options.DecisionHints is set.
+	Hint string
+	// Blocked is true when the request was rejected by the CSRF protection
+	// instead of being passed to the next handler; see
+	// Options.CSRFProtection.
+	Blocked bool
+}
+
+// A DecisionHook is notified of the Decision taken for each CORS request;
+// see Options.DecisionHooks. It is called synchronously on the request path
+// and must be safe for concurrent use.
+type DecisionHook interface {
+	OnDecision(r *http.Request, d Decision)
+}
+
+// DecisionHookFunc is an adapter to use a function as a DecisionHook.
+type DecisionHookFunc func(r *http.Request, d Decision)
+
+// OnDecision calls f(r, d).
+func (f DecisionHookFunc) OnDecision(r *http.Request, d Decision) {
+	f(r, d)
+}
+
+// decision returns the Decision for a request from origin, the rules having
+// taken the decision od.
+func (c *Cors) decision(preflight bool, origin, method string, od originDecision) Decision {
+	d := Decision{
+		Preflight: preflight,
+		Origin:    origin,
+		Method:    method,
+		Allowed:   true,
+		Policy:    c.name,
+	}
+	if od.rule != nil {
+		d.Rule = od.rule.name
+	}
+	return d
+}
+
+// reject returns d rejected for reason.
+func (d Decision) reject(reason string) Decision {
+	d.Allowed = false
+	d.Reason = reason
+	return d
+}
+
+// rejectOrigin returns d rejected because of its origin, with a hint when
+// the origin was not denied but merely not allowed. The hint is only computed
+// when the request is logged or hints are enabled, as it costs a walk over the
+// allowed origins.
+func (c *Cors) rejectOrigin(r *http.Request, d Decision, od originDecision) Decision {
+	d = d.reject(ReasonOrigin)
+	if od.rule != nil && od.rule.deny {
+		return d
+	}
+	if c.decisionHints || (c.Log != nil && c.logged(r)) {
+		d.Hint = c.hint(d.Origin)
+	}
+	return d
+}
+
+// logHint formats a hint as a log message suffix.
+func logHint(hint string) string {
+	if hint == "" {
+		return ""
+	}
+	return ", " + hint
+}
+
+// decisionKey is the context key of the Decisions.
+type decisionKey struct{}
+
+// FromContext returns the Decision taken for the request whose context is
+// ctx, when the handler has Options.DecisionContext set. A Decision with an
+// empty Origin means the request had no Origin header, which doesn't make it
+// a same-origin request: browsers omit the header on cross-origin
+// navigations, and send it on same-origin requests other than GET and HEAD.
+func FromContext(ctx context.Context) (d Decision, ok bool) {
+	d, ok = ctx.Value(decisionKey{}).(Decision)
+	return d, ok
+}
+
+// withDecision returns r with d attached to its context when
+// c.decisionContext is set, r otherwise.
+func (c *Cors) withDecision(r *http.Request, d Decision) *http.Request {
+	if !c.decisionContext {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), decisionKey{}, d))
+}
+
+// notify passes d to the decision hooks.
+func (c *Cors) notify(r *http.Request, d Decision) {
+	for _, h := range c.decisionHooks {
+		h.OnDecision(r, d)
+	}
+}
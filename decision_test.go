@@ -0,0 +1,169 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDecisionHooks(t *testing.T) {
+	var got Decision
+	s := New(Options{
+		Name:           "main",
+		AllowedOrigins: []string{"https://foo.com"},
+		DeniedOrigins:  []string{"https://bar.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPut},
+		AllowedHeaders: []string{"X-Foo"},
+		OriginGroups:   []OriginGroup{{Name: "partners", Origins: []string{"https://acme.com"}}},
+		DecisionHooks: []DecisionHook{DecisionHookFunc(func(r *http.Request, d Decision) {
+			got = d
+		})},
+	})
+	h := s.Handler(testHandler)
+
+	cases := []struct {
+		name       string
+		method     string
+		reqHeaders http.Header
+		decision   Decision
+	}{
+		{
+			"Allowed",
+			http.MethodGet,
+			http.Header{"Origin": {"https://foo.com"}},
+			Decision{Origin: "https://foo.com", Method: http.MethodGet, Allowed: true, Rule: "Options", Policy: "main"},
+		},
+		{
+			"AllowedGroup",
+			http.MethodGet,
+			http.Header{"Origin": {"https://acme.com"}},
+			Decision{Origin: "https://acme.com", Method: http.MethodGet, Allowed: true, Rule: "partners", Policy: "main"},
+		},
+		{
+			"MissingOrigin",
+			http.MethodGet,
+			http.Header{},
+			Decision{Method: http.MethodGet, Reason: ReasonMissingOrigin, Policy: "main"},
+		},
+		{
+			"Denied",
+			http.MethodGet,
+			http.Header{"Origin": {"https://bar.com"}},
+			Decision{Origin: "https://bar.com", Method: http.MethodGet, Reason: ReasonOrigin, Rule: "DeniedOrigins", Policy: "main"},
+		},
+		{
+			"NotAllowed",
+			http.MethodGet,
+			http.Header{"Origin": {"https://baz.com"}},
+			Decision{Origin: "https://baz.com", Method: http.MethodGet, Reason: ReasonOrigin, Policy: "main"},
+		},
+		{
+			"Method",
+			http.MethodDelete,
+			http.Header{"Origin": {"https://foo.com"}},
+			Decision{Origin: "https://foo.com", Method: http.MethodDelete, Reason: ReasonMethod, Rule: "Options", Policy: "main"},
+		},
+		{
+			"PreflightAllowed",
+			http.MethodOptions,
+			http.Header{"Origin": {"https://foo.com"}, "Access-Control-Request-Method": {http.MethodPut}},
+			Decision{Preflight: true, Origin: "https://foo.com", Method: http.MethodPut, Allowed: true, Rule: "Options", Policy: "main"},
+		},
+		{
+			"PreflightMethod",
+			http.MethodOptions,
+			http.Header{"Origin": {"https://foo.com"}, "Access-Control-Request-Method": {http.MethodPatch}},
+			Decision{Preflight: true, Origin: "https://foo.com", Method: http.MethodPatch, Reason: ReasonMethod, Rule: "Options", Policy: "main"},
+		},
+		{
+			"PreflightHeaders",
+			http.MethodOptions,
+			http.Header{"Origin": {"https://foo.com"}, "Access-Control-Request-Method": {http.MethodGet}, "Access-Control-Request-Headers": {"x-bar"}},
+			Decision{Preflight: true, Origin: "https://foo.com", Method: http.MethodGet, Reason: ReasonHeaders, Rule: "Options", Policy: "main"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got = Decision{}
+			req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+			req.Header = tc.reqHeaders
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tc.decision {
+				t.Errorf("got decision %+v, want %+v", got, tc.decision)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var got Decision
+	var ok bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok = FromContext(r.Context())
+	})
+	s := New(Options{
+		AllowedOrigins:     []string{"https://foo.com"},
+		DecisionContext:    true,
+		OptionsPassthrough: true,
+	})
+
+	cases := []struct {
+		name       string
+		method     string
+		reqHeaders http.Header
+		decision   Decision
+	}{
+		{
+			"Allowed",
+			http.MethodGet,
+			http.Header{"Origin": {"https://foo.com"}},
+			Decision{Origin: "https://foo.com", Method: http.MethodGet, Allowed: true, Rule: "Options"},
+		},
+		{
+			"NotAllowed",
+			http.MethodPost,
+			http.Header{"Origin": {"http://foo.com"}},
+			Decision{Origin: "http://foo.com", Method: http.MethodPost, Reason: ReasonOrigin},
+		},
+		{
+			"SameOrigin",
+			http.MethodGet,
+			http.Header{},
+			Decision{Method: http.MethodGet, Reason: ReasonMissingOrigin},
+		},
+		{
+			"Preflight",
+			http.MethodOptions,
+			http.Header{"Origin": {"https://foo.com"}, "Access-Control-Request-Method": {http.MethodPost}},
+			Decision{Preflight: true, Origin: "https://foo.com", Method: http.MethodPost, Allowed: true, Rule: "Options"},
+		},
+	}
+	handlers := map[string]http.Handler{
+		"Handler": s.Handler(next),
+		"ServeHTTP": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s.ServeHTTP(w, r, next)
+		}),
+	}
+	for hname, h := range handlers {
+		for _, tc := range cases {
+			t.Run(hname+"/"+tc.name, func(t *testing.T) {
+				got, ok = Decision{}, false
+				req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+				req.Header = tc.reqHeaders
+				h.ServeHTTP(httptest.NewRecorder(), req)
+				if !ok || got != tc.decision {
+					t.Errorf("got decision %+v, %v, want %+v", got, ok, tc.decision)
+				}
+			})
+		}
+	}
+
+	// Not attached by default
+	h := New(Options{}).Handler(next)
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Set("Origin", "https://foo.com")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if ok {
+		t.Errorf("got decision %+v without DecisionContext", got)
+	}
+}
@@ -0,0 +1,48 @@
+package cors
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// DevelopmentEnv is the environment variable which must be set to a true
+// value (as understood by strconv.ParseBool) for Options.DevelopmentOrigins to
+// take effect.
+const DevelopmentEnv = "CORS_DEVELOPMENT"
+
+// developmentOrigins are the origins allowed by Options.DevelopmentOrigins,
+// expanded for the http and https schemes.
+var developmentOrigins = []string{
+	"//localhost:*",
+	"//127.0.0.1:*",
+	"//[::1]:*",
+	"//*.localhost:*",
+}
+
+// developmentEnabled reports whether the DevelopmentEnv switch is on.
+func developmentEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(DevelopmentEnv))
+	return enabled
+}
+
+// Development creates a new Cors handler for local development, allowing any
+// localhost origin (localhost, 127.0.0.1, [::1] and *.localhost on any port
+// with the http and https schemes) with all standard methods, any header and
+// credentials. It only allows those origins when the DevelopmentEnv
+// environment variable is set, and none otherwise.
+func Development() *Cors {
+	return New(Options{
+		DevelopmentOrigins: true,
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		AllowedHeaders:   []string{"*"},
+		AllowCredentials: true,
+	})
+}
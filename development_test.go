@@ -0,0 +1,74 @@
+package cors
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDevelopmentOrigins(t *testing.T) {
+	origins := []struct {
+		origin string
+		dev    bool
+	}{
+		{"http://localhost:3000", true},
+		{"https://localhost", true},
+		{"http://127.0.0.1:8080", true},
+		{"http://[::1]:5173", true},
+		{"http://app.localhost:3000", true},
+		{"http://localhost.example.com", false},
+		{"http://192.168.1.1:3000", false},
+		{"https://foobar.com", false},
+	}
+	t.Run("Enabled", func(t *testing.T) {
+		t.Setenv(DevelopmentEnv, "true")
+		logger := &testLogger{buf: &bytes.Buffer{}}
+		c := New(Options{
+			AllowedOrigins:     []string{"https://foobar.com"},
+			DevelopmentOrigins: true,
+			Logger:             logger,
+		})
+		if !strings.Contains(logger.buf.String(), "WARNING: development origins are allowed") {
+			t.Errorf("New should warn when development origins are allowed, got %q", logger.buf.String())
+		}
+		for _, tc := range origins {
+			want := tc.dev || tc.origin == "https://foobar.com"
+			if allowed, _ := c.isOriginAllowed(nil, tc.origin); allowed != want {
+				t.Errorf("origin %q allowed = %t, want %t", tc.origin, allowed, want)
+			}
+		}
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		t.Setenv(DevelopmentEnv, "")
+		c := New(Options{
+			AllowedOrigins:     []string{"https://foobar.com"},
+			DevelopmentOrigins: true,
+		})
+		for _, tc := range origins {
+			want := tc.origin == "https://foobar.com"
+			if allowed, _ := c.isOriginAllowed(nil, tc.origin); allowed != want {
+				t.Errorf("origin %q allowed = %t, want %t", tc.origin, allowed, want)
+			}
+		}
+	})
+}
+
+func TestDevelopment(t *testing.T) {
+	t.Setenv(DevelopmentEnv, "0")
+	c := Development()
+	if c.allowedOriginsAll {
+		t.Error("Development should not allow all origins when disabled")
+	}
+	if allowed, _ := c.isOriginAllowed(nil, "http://localhost:3000"); allowed {
+		t.Error("Development should not allow localhost when disabled")
+	}
+
+	t.Setenv(DevelopmentEnv, "1")
+	c = Development()
+	if allowed, _ := c.isOriginAllowed(nil, "http://localhost:3000"); !allowed {
+		t.Error("Development should allow localhost when enabled")
+	}
+	if !c.allowCredentials {
+		t.Error("Development should allow credentials")
+	}
+}
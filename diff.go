@@ -0,0 +1,353 @@
+package cors
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A PolicyDiff describes the changes between two effective policies.
+type PolicyDiff struct {
+	// Changes of the settings of Options.
+	SettingsDiff
+	// Origins added to and removed from Options.DeniedOrigins.
+	AddedDeniedOrigins   []string `json:"addedDeniedOrigins,omitempty"`
+	RemovedDeniedOrigins []string `json:"removedDeniedOrigins,omitempty"`
+	// Changes of the origin groups, by group name.
+	Groups []GroupDiff `json:"groups,omitempty"`
+	// Changes of Options.Rules, by rule name.
+	Rules []RuleDiff `json:"rules,omitempty"`
+	// Changes of the other options allowing origins. OriginFunc tells
+	// whether AllowOriginFunc, one of its variants or an OriginStore decides
+	// which origins are allowed; changes of the function or store themselves
+	// can't be detected.
+	AllowNullOrigin    *BoolChange             `json:"allowNullOrigin,omitempty"`
+	NullOriginPolicy   *NullOriginPolicyChange `json:"nullOriginPolicy,omitempty"`
+	DevelopmentOrigins *BoolChange             `json:"developmentOrigins,omitempty"`
+	OriginFunc         *BoolChange             `json:"originFunc,omitempty"`
+	// Change of Options.AllowPrivateNetwork.
+	AllowPrivateNetwork *BoolChange `json:"allowPrivateNetwork,omitempty"`
+}
+
+// A SettingsDiff describes the changes of the settings of a policy or of an
+// origin group. Headers and origins are compared once normalized, and
+// methods as configured since they are matched case-sensitively, with
+// defaults applied: an origin list allowing all origins holds "*". A
+// negative MaxAge is reported as -1.
+type SettingsDiff struct {
+	AddedOrigins          []string    `json:"addedOrigins,omitempty"`
+	RemovedOrigins        []string    `json:"removedOrigins,omitempty"`
+	AddedMethods          []string    `json:"addedMethods,omitempty"`
+	RemovedMethods        []string    `json:"removedMethods,omitempty"`
+	AddedHeaders          []string    `json:"addedHeaders,omitempty"`
+	RemovedHeaders        []string    `json:"removedHeaders,omitempty"`
+	AddedExposedHeaders   []string    `json:"addedExposedHeaders,omitempty"`
+	RemovedExposedHeaders []string    `json:"removedExposedHeaders,omitempty"`
+	MaxAge                *IntChange  `json:"maxAge,omitempty"`
+	AllowCredentials      *BoolChange `json:"allowCredentials,omitempty"`
+}
+
+// A GroupDiff describes the changes of an origin group. A group added or
+// removed has all its origins added or removed.
+type GroupDiff struct {
+	Name string `json:"name"`
+	SettingsDiff
+}
+
+// A RuleDiff describes a rule added, removed, changed or moved within
+// Options.Rules, rules being identified by name (or "#" followed by their
+// index when unnamed). Old is nil for an added rule, and New for a removed
+// one. Their settings are normalized like the ones of a SettingsDiff, empty
+// settings being inherited from Options.
+type RuleDiff struct {
+	Name string `json:"name"`
+	// Index of the rule in Options.Rules, -1 when absent.
+	OldIndex int   `json:"oldIndex"`
+	NewIndex int   `json:"newIndex"`
+	Old      *Rule `json:"old,omitempty"`
+	New      *Rule `json:"new,omitempty"`
+}
+
+// A BoolChange describes the change of a boolean setting.
+type BoolChange struct {
+	Old bool `json:"old"`
+	New bool `json:"new"`
+}
+
+// An IntChange describes the change of an integer setting.
+type IntChange struct {
+	Old int `json:"old"`
+	New int `json:"new"`
+}
+
+// A NullOriginPolicyChange describes the change of Options.NullOriginPolicy.
+type NullOriginPolicyChange struct {
+	Old NullOriginPolicy `json:"old"`
+	New NullOriginPolicy `json:"new"`
+}
+
+// Empty reports whether the settings are unchanged.
+func (d SettingsDiff) Empty() bool {
+	return len(d.AddedOrigins) == 0 && len(d.RemovedOrigins) == 0 &&
+		len(d.AddedMethods) == 0 && len(d.RemovedMethods) == 0 &&
+		len(d.AddedHeaders) == 0 && len(d.RemovedHeaders) == 0 &&
+		len(d.AddedExposedHeaders) == 0 && len(d.RemovedExposedHeaders) == 0 &&
+		d.MaxAge == nil && d.AllowCredentials == nil
+}
+
+// Empty reports whether the policies are identical.
+func (d PolicyDiff) Empty() bool {
+	return d.SettingsDiff.Empty() && len(d.AddedDeniedOrigins) == 0 &&
+		len(d.RemovedDeniedOrigins) == 0 && len(d.Groups) == 0 && len(d.Rules) == 0 &&
+		d.AllowNullOrigin == nil && d.NullOriginPolicy == nil &&
+		d.DevelopmentOrigins == nil && d.OriginFunc == nil && d.AllowPrivateNetwork == nil
+}
+
+// DiffOptions returns the changes of the effective policy from the old
+// options to the new ones.
+func DiffOptions(old, new Options) PolicyDiff {
+	oldSettings, newSettings := effectiveSettings(old), effectiveSettings(new)
+	d := PolicyDiff{
+		SettingsDiff: diffSettings(oldSettings, newSettings),
+	}
+	d.AddedDeniedOrigins, d.RemovedDeniedOrigins = diffSets(normalizeSet(old.DeniedOrigins), normalizeSet(new.DeniedOrigins))
+	d.AllowNullOrigin = diffBool(allowsNullOrigin(old), allowsNullOrigin(new))
+	if oldNull, newNull := effectiveNullOriginPolicy(old), effectiveNullOriginPolicy(new); oldNull != newNull {
+		d.NullOriginPolicy = &NullOriginPolicyChange{Old: oldNull, New: newNull}
+	}
+	d.DevelopmentOrigins = diffBool(old.DevelopmentOrigins, new.DevelopmentOrigins)
+	d.OriginFunc = diffBool(old.hasOriginFunc(), new.hasOriginFunc())
+	d.AllowPrivateNetwork = diffBool(old.AllowPrivateNetwork, new.AllowPrivateNetwork)
+	d.Rules = diffRules(old.Rules, new.Rules)
+
+	var names []string
+	for _, g := range slices.Concat(old.OriginGroups, new.OriginGroups) {
+		if !slices.Contains(names, g.Name) {
+			names = append(names, g.Name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		oldGroup, newGroup := oldSettings.group(old.OriginGroups, name), newSettings.group(new.OriginGroups, name)
+		gd := diffSettings(oldGroup, newGroup)
+		if !gd.Empty() {
+			d.Groups = append(d.Groups, GroupDiff{Name: name, SettingsDiff: gd})
+		}
+	}
+	return d
+}
+
+// settings are the normalized settings of a policy or an origin group.
+type settings struct {
+	origins, methods, headers, exposedHeaders []string
+	maxAge                                    int
+	allowCredentials                          bool
+}
+
+// effectiveSettings returns the normalized settings of the options.
+func effectiveSettings(o Options) settings {
+	return settings{
+		origins:          effectiveOrigins(o),
+		methods:          effectiveMethods(o.AllowedMethods),
+		headers:          effectiveHeaders(o.AllowedHeaders),
+		exposedHeaders:   normalizeHeaders(o.ExposedHeaders),
+		maxAge:           effectiveMaxAge(o.MaxAge),
+		allowCredentials: o.AllowCredentials,
+	}
+}
+
+// group returns the settings of the named group, inheriting the unset ones
+// from s. A missing group has no origins.
+func (s settings) group(groups []OriginGroup, name string) settings {
+	gs := s
+	gs.origins, gs.allowCredentials = nil, false
+	i := slices.IndexFunc(groups, func(g OriginGroup) bool { return g.Name == name })
+	if i < 0 {
+		return gs
+	}
+	g := groups[i]
+	gs.origins = normalizeSet(g.Origins)
+	if len(g.AllowedMethods) > 0 {
+		gs.methods = effectiveMethods(g.AllowedMethods)
+	}
+	if len(g.ExposedHeaders) > 0 {
+		gs.exposedHeaders = normalizeHeaders(g.ExposedHeaders)
+	}
+	if g.MaxAge != 0 {
+		gs.maxAge = effectiveMaxAge(g.MaxAge)
+	}
+	gs.allowCredentials = g.AllowCredentials
+	return gs
+}
+
+func diffSettings(old, new settings) SettingsDiff {
+	var d SettingsDiff
+	d.AddedOrigins, d.RemovedOrigins = diffSets(old.origins, new.origins)
+	d.AddedMethods, d.RemovedMethods = diffSets(old.methods, new.methods)
+	d.AddedHeaders, d.RemovedHeaders = diffSets(old.headers, new.headers)
+	d.AddedExposedHeaders, d.RemovedExposedHeaders = diffSets(old.exposedHeaders, new.exposedHeaders)
+	if old.maxAge != new.maxAge {
+		d.MaxAge = &IntChange{Old: old.maxAge, New: new.maxAge}
+	}
+	d.AllowCredentials = diffBool(old.allowCredentials, new.allowCredentials)
+	return d
+}
+
+// diffRules returns the changes of the rules, sorted by name.
+func diffRules(old, new []Rule) []RuleDiff {
+	oldRules, newRules := normalizeRules(old), normalizeRules(new)
+	var names []string
+	for _, r := range slices.Concat(oldRules, newRules) {
+		if !slices.Contains(names, r.Name) {
+			names = append(names, r.Name)
+		}
+	}
+	slices.Sort(names)
+	var diffs []RuleDiff
+	for _, name := range names {
+		rd := RuleDiff{Name: name, OldIndex: -1, NewIndex: -1}
+		if i := slices.IndexFunc(oldRules, func(r Rule) bool { return r.Name == name }); i >= 0 {
+			rd.OldIndex, rd.Old = i, &oldRules[i]
+		}
+		if i := slices.IndexFunc(newRules, func(r Rule) bool { return r.Name == name }); i >= 0 {
+			rd.NewIndex, rd.New = i, &newRules[i]
+		}
+		if rd.OldIndex == rd.NewIndex && reflect.DeepEqual(rd.Old, rd.New) {
+			continue
+		}
+		diffs = append(diffs, rd)
+	}
+	return diffs
+}
+
+// normalizeRules returns the rules with their names defaulted like New does,
+// and their origins, methods and headers normalized.
+func normalizeRules(rules []Rule) []Rule {
+	normalized := make([]Rule, len(rules))
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = "#" + strconv.Itoa(i)
+		}
+		r.Origins = normalizeSet(r.Origins)
+		r.AllowedMethods = sortedSet(append([]string{}, r.AllowedMethods...))
+		r.AllowedHeaders = sortedSet(convert(r.AllowedHeaders, strings.ToLower))
+		r.ExposedHeaders = normalizeHeaders(r.ExposedHeaders)
+		r.MaxAge = effectiveMaxAge(r.MaxAge)
+		normalized[i] = r
+	}
+	return normalized
+}
+
+func diffBool(old, new bool) *BoolChange {
+	if old == new {
+		return nil
+	}
+	return &BoolChange{Old: old, New: new}
+}
+
+// hasOriginFunc reports whether a function or an OriginStore decides which
+// origins are allowed instead of AllowedOrigins.
+func (o Options) hasOriginFunc() bool {
+	return o.AllowOriginFunc != nil || o.AllowOriginRequestFunc != nil ||
+		o.AllowOriginVaryRequestFunc != nil || o.OriginStore != nil
+}
+
+// allowsNullOrigin reports whether the null origin is explicitly allowed.
+func allowsNullOrigin(o Options) bool {
+	return o.AllowNullOrigin || slices.ContainsFunc(o.AllowedOrigins, func(origin string) bool {
+		return strings.EqualFold(origin, nullOrigin)
+	})
+}
+
+// effectiveNullOriginPolicy returns the policy applied to the null origin,
+// which gets credentials when listed in AllowedOrigins.
+func effectiveNullOriginPolicy(o Options) NullOriginPolicy {
+	p := o.NullOriginPolicy
+	if slices.ContainsFunc(o.AllowedOrigins, func(origin string) bool {
+		return strings.EqualFold(origin, nullOrigin)
+	}) {
+		p.AllowCredentials = true
+	}
+	return p
+}
+
+// effectiveOrigins returns the origins allowed by the options, "*" standing
+// for all origins. The null origin is reported with AllowNullOrigin.
+func effectiveOrigins(o Options) []string {
+	if len(o.AllowedOrigins) == 0 && !o.hasOriginOptions() && !o.hasOriginFunc() {
+		return []string{"*"}
+	}
+	return normalizeSet(slices.DeleteFunc(slices.Clone(o.AllowedOrigins), func(origin string) bool {
+		return strings.EqualFold(origin, nullOrigin)
+	}))
+}
+
+// effectiveHeaders returns the headers allowed by the options, defaults
+// applied.
+func effectiveHeaders(headers []string) []string {
+	if len(headers) == 0 {
+		return []string{"accept", "content-type", "x-requested-with"}
+	}
+	if slices.Contains(headers, "*") {
+		return []string{"*"}
+	}
+	return sortedSet(convert(headers, strings.ToLower))
+}
+
+// normalizeHeaders returns the canonical form of headers, sorted and
+// de-duplicated.
+func normalizeHeaders(headers []string) []string {
+	return sortedSet(convert(headers, http.CanonicalHeaderKey))
+}
+
+// effectiveMaxAge returns maxAge, negative values, which all send a 0
+// max-age, being reported as -1.
+func effectiveMaxAge(maxAge int) int {
+	return max(maxAge, -1)
+}
+
+// effectiveMethods returns the methods allowed by the options, defaults
+// applied. Their case is kept, as requests are matched case-sensitively.
+func effectiveMethods(methods []string) []string {
+	if len(methods) == 0 {
+		return []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	return sortedSet(append([]string{}, methods...))
+}
+
+// normalizeSet returns the elements of s lowercased and normalized when
+// they are origins, sorted and de-duplicated.
+func normalizeSet(s []string) []string {
+	set := make([]string, 0, len(s))
+	for _, e := range s {
+		e = strings.ToLower(e)
+		if normalized, ok := normalizeOrigin(e); ok {
+			e = normalized
+		}
+		set = append(set, e)
+	}
+	return sortedSet(set)
+}
+
+func sortedSet(s []string) []string {
+	slices.Sort(s)
+	return slices.Compact(s)
+}
+
+// diffSets returns the elements of the sorted set b not in a, and the ones
+// of a not in b.
+func diffSets(a, b []string) (added, removed []string) {
+	for _, e := range b {
+		if _, found := slices.BinarySearch(a, e); !found {
+			added = append(added, e)
+		}
+	}
+	for _, e := range a {
+		if _, found := slices.BinarySearch(b, e); !found {
+			removed = append(removed, e)
+		}
+	}
+	return added, removed
+}
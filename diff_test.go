@@ -0,0 +1,286 @@
+package cors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDiffOptions(t *testing.T) {
+	cases := []struct {
+		name string
+		old  Options
+		new  Options
+		diff PolicyDiff
+	}{
+		{
+			"Identical",
+			Options{AllowedOrigins: []string{"https://foo.com", "https://bar.com:443"}},
+			Options{AllowedOrigins: []string{"https://BAR.com", "https://foo.com", "https://foo.com"}},
+			PolicyDiff{},
+		},
+		{
+			"Origins",
+			Options{AllowedOrigins: []string{"https://foo.com", "https://bar.com"}},
+			Options{AllowedOrigins: []string{"https://bar.com", "https://*.baz.com"}},
+			PolicyDiff{SettingsDiff: SettingsDiff{
+				AddedOrigins:   []string{"https://*.baz.com"},
+				RemovedOrigins: []string{"https://foo.com"},
+			}},
+		},
+		{
+			"AllOrigins",
+			Options{AllowedOrigins: []string{"https://foo.com"}},
+			Options{},
+			PolicyDiff{SettingsDiff: SettingsDiff{
+				AddedOrigins:   []string{"*"},
+				RemovedOrigins: []string{"https://foo.com"},
+			}},
+		},
+		{
+			"MethodsAndCredentials",
+			Options{AllowedOrigins: []string{"https://foo.com"}},
+			Options{AllowedOrigins: []string{"https://foo.com"}, AllowedMethods: []string{"get", http.MethodDelete}, AllowCredentials: true},
+			PolicyDiff{SettingsDiff: SettingsDiff{
+				AddedMethods:     []string{http.MethodDelete, "get"},
+				RemovedMethods:   []string{http.MethodGet, http.MethodHead, http.MethodPost},
+				AllowCredentials: &BoolChange{Old: false, New: true},
+			}},
+		},
+		{
+			"MethodCase",
+			Options{AllowedOrigins: []string{"https://foo.com"}, AllowedMethods: []string{http.MethodGet}},
+			Options{AllowedOrigins: []string{"https://foo.com"}, AllowedMethods: []string{"get"}},
+			PolicyDiff{SettingsDiff: SettingsDiff{
+				AddedMethods:   []string{"get"},
+				RemovedMethods: []string{http.MethodGet},
+			}},
+		},
+		{
+			"HeadersAndMaxAge",
+			Options{AllowedOrigins: []string{"https://foo.com"}, ExposedHeaders: []string{"x-total"}, MaxAge: -5},
+			Options{AllowedOrigins: []string{"https://foo.com"}, AllowedHeaders: []string{"Accept", "Authorization"}, ExposedHeaders: []string{"X-Total", "x-page"}, MaxAge: 600},
+			PolicyDiff{SettingsDiff: SettingsDiff{
+				AddedHeaders:        []string{"authorization"},
+				RemovedHeaders:      []string{"content-type", "x-requested-with"},
+				AddedExposedHeaders: []string{"X-Page"},
+				MaxAge:              &IntChange{Old: -1, New: 600},
+			}},
+		},
+		{
+			"NullOrigin",
+			Options{AllowedOrigins: []string{"https://foo.com", "null"}},
+			Options{AllowedOrigins: []string{"https://foo.com"}, NullOriginPolicy: NullOriginPolicy{EchoOrigin: true}},
+			PolicyDiff{
+				AllowNullOrigin: &BoolChange{Old: true, New: false},
+				NullOriginPolicy: &NullOriginPolicyChange{
+					Old: NullOriginPolicy{AllowCredentials: true},
+					New: NullOriginPolicy{EchoOrigin: true},
+				},
+			},
+		},
+		{
+			"OriginOptions",
+			Options{AllowedOrigins: []string{"https://foo.com"}},
+			Options{AllowedOrigins: []string{"https://foo.com"}, DevelopmentOrigins: true, AllowPrivateNetwork: true, OriginStore: NewDynamicOrigins(DynamicOriginsOptions{})},
+			PolicyDiff{
+				DevelopmentOrigins:  &BoolChange{Old: false, New: true},
+				OriginFunc:          &BoolChange{Old: false, New: true},
+				AllowPrivateNetwork: &BoolChange{Old: false, New: true},
+			},
+		},
+		{
+			"Rules",
+			Options{Rules: []Rule{
+				{Name: "partners", Origins: []string{"https://acme.com"}},
+				{Name: "legacy", Origins: []string{"https://old.com"}},
+				{Origins: []string{"https://same.com"}},
+			}},
+			Options{Rules: []Rule{
+				{Name: "legacy", Origins: []string{"https://old.com"}},
+				{Name: "partners", Origins: []string{"https://ACME.com:443"}},
+				{Origins: []string{"https://same.com"}},
+				{Name: "all", Origins: []string{"*"}, AllowCredentials: true},
+			}},
+			PolicyDiff{Rules: []RuleDiff{
+				{Name: "all", OldIndex: -1, NewIndex: 3, New: &Rule{Name: "all", Origins: []string{"*"}, AllowedMethods: []string{}, AllowedHeaders: []string{}, ExposedHeaders: []string{}, AllowCredentials: true}},
+				{Name: "legacy", OldIndex: 1, NewIndex: 0,
+					Old: &Rule{Name: "legacy", Origins: []string{"https://old.com"}, AllowedMethods: []string{}, AllowedHeaders: []string{}, ExposedHeaders: []string{}},
+					New: &Rule{Name: "legacy", Origins: []string{"https://old.com"}, AllowedMethods: []string{}, AllowedHeaders: []string{}, ExposedHeaders: []string{}}},
+				{Name: "partners", OldIndex: 0, NewIndex: 1,
+					Old: &Rule{Name: "partners", Origins: []string{"https://acme.com"}, AllowedMethods: []string{}, AllowedHeaders: []string{}, ExposedHeaders: []string{}},
+					New: &Rule{Name: "partners", Origins: []string{"https://acme.com"}, AllowedMethods: []string{}, AllowedHeaders: []string{}, ExposedHeaders: []string{}}},
+			}},
+		},
+		{
+			"DeniedOrigins",
+			Options{DeniedOrigins: []string{"https://foo.com"}},
+			Options{DeniedOrigins: []string{"https://bar.com"}},
+			PolicyDiff{
+				AddedDeniedOrigins:   []string{"https://bar.com"},
+				RemovedDeniedOrigins: []string{"https://foo.com"},
+			},
+		},
+		{
+			"Groups",
+			Options{OriginGroups: []OriginGroup{
+				{Name: "partners", Origins: []string{"https://acme.com"}},
+				{Name: "legacy", Origins: []string{"https://old.com"}, AllowCredentials: true},
+			}},
+			Options{OriginGroups: []OriginGroup{
+				{Name: "partners", Origins: []string{"https://acme.com"}, AllowedMethods: []string{http.MethodGet}, AllowCredentials: true},
+				{Name: "vendors", Origins: []string{"https://vendor.com"}},
+			}},
+			PolicyDiff{Groups: []GroupDiff{
+				{Name: "legacy", SettingsDiff: SettingsDiff{
+					RemovedOrigins:   []string{"https://old.com"},
+					AllowCredentials: &BoolChange{Old: true, New: false},
+				}},
+				{Name: "partners", SettingsDiff: SettingsDiff{
+					RemovedMethods:   []string{http.MethodHead, http.MethodPost},
+					AllowCredentials: &BoolChange{Old: false, New: true},
+				}},
+				{Name: "vendors", SettingsDiff: SettingsDiff{
+					AddedOrigins: []string{"https://vendor.com"},
+				}},
+			}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := DiffOptions(tc.old, tc.new)
+			if !reflect.DeepEqual(diff, tc.diff) {
+				got, _ := json.Marshal(diff)
+				want, _ := json.Marshal(tc.diff)
+				t.Errorf("got diff %s, want %s", got, want)
+			}
+			if diff.Empty() != (tc.name == "Identical") {
+				t.Errorf("got Empty() %v", diff.Empty())
+			}
+		})
+	}
+}
+
+func TestReloadablePolicyAudit(t *testing.T) {
+	var entries []AuditEntry
+	p, err := NewReloadablePolicy(context.Background(), Options{
+		AllowedOrigins: []string{"https://foo.com"},
+	}, ReloadablePolicyOptions{
+		AuditLog: AuditLogFunc(func(ctx context.Context, e AuditEntry) error {
+			entries = append(entries, e)
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithActor(context.Background(), "deploy-bot")
+
+	err = p.SetOptions(ctx, Options{
+		AllowedOrigins:   []string{"https://foo.com"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPut},
+		AllowCredentials: true,
+	}, "release 1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Changes without effect on the policy are recorded too
+	if err := p.Reload(ctx, "periodic reload"); err != nil {
+		t.Fatal(err)
+	}
+	// Invalid options are rejected
+	if err := p.SetOptions(ctx, Options{AllowedOrigins: []string{"https://foo.com/path"}}, ""); err == nil {
+		t.Error("invalid options should be rejected")
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2", len(entries))
+	}
+	if e := entries[1]; e.Action != AuditReload || e.Diff == nil || !e.Diff.Empty() {
+		t.Errorf("got audit entry %+v, want a reload with an empty diff", e)
+	}
+	e := entries[0]
+	if e.Action != AuditUpdate || e.Actor != "deploy-bot" || e.Reason != "release 1.2" {
+		t.Errorf("got audit entry %+v", e)
+	}
+	want := &PolicyDiff{SettingsDiff: SettingsDiff{
+		AddedMethods:     []string{http.MethodPut},
+		RemovedMethods:   []string{http.MethodHead, http.MethodPost},
+		AllowCredentials: &BoolChange{Old: false, New: true},
+	}}
+	if !reflect.DeepEqual(e.Diff, want) {
+		t.Errorf("got diff %+v, want %+v", e.Diff, want)
+	}
+	if !p.Cors().allowCredentials {
+		t.Error("new options not applied")
+	}
+}
+
+func TestReloadablePolicyAuditRules(t *testing.T) {
+	var entries []AuditEntry
+	base := Options{AllowedOrigins: []string{"https://foo.com"}}
+	p, err := NewReloadablePolicy(context.Background(), base, ReloadablePolicyOptions{
+		AuditLog: AuditLogFunc(func(ctx context.Context, e AuditEntry) error {
+			entries = append(entries, e)
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	err = p.SetOptions(ctx, Options{
+		AllowedOrigins:  []string{"https://foo.com"},
+		AllowNullOrigin: true,
+		Rules:           []Rule{{Origins: []string{"*"}, AllowCredentials: true}},
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Listed already, in another form
+	if err := p.Allow(ctx, "https://foo.com:443", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2", len(entries))
+	}
+	d := entries[0].Diff
+	if d.Empty() || len(d.Rules) != 1 || d.Rules[0].New == nil || !d.Rules[0].New.AllowCredentials ||
+		d.AllowNullOrigin == nil || !d.AllowNullOrigin.New {
+		t.Errorf("got diff %+v, want the added rule and null origin", d)
+	}
+	if e := entries[1]; e.Action != AuditAdd || e.Origin != "https://foo.com:443" || !e.Diff.Empty() {
+		t.Errorf("got audit entry %+v", e)
+	}
+}
+
+func TestReloadablePolicyAuditLastOrigin(t *testing.T) {
+	var entries []AuditEntry
+	p, err := NewReloadablePolicy(context.Background(), Options{}, ReloadablePolicyOptions{
+		Store: NewMemoryPolicyStore(ManagedOrigins{AllowedOrigins: []string{"https://foo.com"}}),
+		AuditLog: AuditLogFunc(func(ctx context.Context, e AuditEntry) error {
+			entries = append(entries, e)
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.RemoveAllowed(context.Background(), "https://foo.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	// The removal of the last origin allows no origin, without a change
+	// of the origin function no actor made
+	want := &PolicyDiff{SettingsDiff: SettingsDiff{RemovedOrigins: []string{"https://foo.com"}}}
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].Diff, want) {
+		t.Errorf("got audit entries %+v, want one with diff %+v", entries, want)
+	}
+	h := New(Options{PolicyResolver: p.Resolve}).Handler(testHandler)
+	if reloadableAllowed(t, h, "https://bar.com") {
+		t.Error("removing the last origin should not allow all origins")
+	}
+}
@@ -0,0 +1,214 @@
+package cors
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// An OriginGrant is an entry of a DynamicOrigins list.
+type OriginGrant struct {
+	// Pattern is the entry, using the same syntax as
+	// `Options.AllowedOrigins` (except `*`).
+	Pattern string
+	// Expires is when the grant expires, zero for a permanent grant.
+	Expires time.Time
+}
+
+// DynamicOriginsOptions configures a DynamicOrigins list.
+type DynamicOriginsOptions struct {
+	// DefaultSchemes lists the schemes used to expand entries without one.
+	// Default value is http and https.
+	DefaultSchemes []string
+	// OnExpire is called with each grant pruned because it expired.
+	OnExpire func(OriginGrant)
+	// Now returns the current time. Default value is time.Now.
+	Now func() time.Time
+}
+
+// DynamicOrigins is an OriginStore serving a list of origins which can be
+// modified at runtime, including temporary grants expiring on their own.
+// Expired grants are pruned on the first lookup after their expiry, by
+// Prune, or as soon as they expire while Run is running, so OnExpire is
+// called even without traffic. It is safe for concurrent use.
+type DynamicOrigins struct {
+	schemes  []string
+	onExpire func(OriginGrant)
+	now      func() time.Time
+
+	// Compiled list of the grants, replaced on each change
+	origins atomic.Pointer[originList]
+	// Earliest expiry of the grants, in Unix nanoseconds, 0 if none expires
+	nextExpiry atomic.Int64
+	// Signaled when the grants change, so Run rearms its timer
+	changed chan struct{}
+
+	mu     sync.Mutex
+	grants map[string]OriginGrant
+}
+
+// NewDynamicOrigins creates an empty DynamicOrigins list.
+func NewDynamicOrigins(options DynamicOriginsOptions) *DynamicOrigins {
+	d := &DynamicOrigins{
+		schemes:  options.DefaultSchemes,
+		onExpire: options.OnExpire,
+		now:      options.Now,
+		grants:   map[string]OriginGrant{},
+		changed:  make(chan struct{}, 1),
+	}
+	if len(d.schemes) == 0 {
+		d.schemes = defaultSchemes
+	}
+	if d.now == nil {
+		d.now = time.Now
+	}
+	d.origins.Store(&originList{})
+	return d
+}
+
+// AllowOrigin permanently grants access to the origins matching pattern,
+// replacing any previous grant of the same pattern.
+func (d *DynamicOrigins) AllowOrigin(pattern string) error {
+	return d.grant(OriginGrant{Pattern: pattern})
+}
+
+// AllowOriginUntil grants access to the origins matching pattern until the
+// given time, replacing any previous grant of the same pattern.
+func (d *DynamicOrigins) AllowOriginUntil(pattern string, until time.Time) error {
+	if until.IsZero() || !d.now().Before(until) {
+		return originError(pattern, "the grant expiry is in the past")
+	}
+	return d.grant(OriginGrant{Pattern: pattern, Expires: until})
+}
+
+func (d *DynamicOrigins) grant(g OriginGrant) error {
+	g.Pattern = strings.ToLower(g.Pattern)
+	var l originList
+	if err := l.add(g.Pattern, d.schemes); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.grants[g.Pattern] = g
+	expired := d.update()
+	d.mu.Unlock()
+	d.notify(expired)
+	return nil
+}
+
+// RemoveOrigin removes the grant of pattern, reporting whether there was one.
+func (d *DynamicOrigins) RemoveOrigin(pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	d.mu.Lock()
+	_, found := d.grants[pattern]
+	delete(d.grants, pattern)
+	expired := d.update()
+	d.mu.Unlock()
+	d.notify(expired)
+	return found
+}
+
+// Grants returns the current grants, sorted by pattern.
+func (d *DynamicOrigins) Grants() []OriginGrant {
+	d.Prune()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	grants := make([]OriginGrant, 0, len(d.grants))
+	for _, g := range d.grants {
+		grants = append(grants, g)
+	}
+	slices.SortFunc(grants, func(a, b OriginGrant) int {
+		return strings.Compare(a.Pattern, b.Pattern)
+	})
+	return grants
+}
+
+// Prune removes the expired grants.
+func (d *DynamicOrigins) Prune() {
+	if !d.expiring() {
+		return
+	}
+	d.mu.Lock()
+	expired := d.update()
+	d.mu.Unlock()
+	d.notify(expired)
+}
+
+// Run prunes the grants as soon as they expire, until ctx is done.
+func (d *DynamicOrigins) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-d.changed:
+		}
+		d.Prune()
+		timer.Stop()
+		if next := d.nextExpiry.Load(); next != 0 {
+			timer.Reset(time.Duration(next - d.now().UnixNano()))
+		}
+	}
+}
+
+// Lookup reports whether origin matches one of the grants.
+func (d *DynamicOrigins) Lookup(ctx context.Context, origin string) (bool, error) {
+	d.Prune()
+	return d.origins.Load().contains(origin), nil
+}
+
+// expiring reports whether a grant may have expired.
+func (d *DynamicOrigins) expiring() bool {
+	next := d.nextExpiry.Load()
+	return next != 0 && d.now().UnixNano() >= next
+}
+
+// update prunes the expired grants and compiles the remaining ones,
+// returning the expired grants. It must be called with mu held.
+func (d *DynamicOrigins) update() (expired []OriginGrant) {
+	now := d.now()
+	l := &originList{}
+	var next time.Time
+	for pattern, g := range d.grants {
+		if !g.Expires.IsZero() {
+			if !now.Before(g.Expires) {
+				expired = append(expired, g)
+				delete(d.grants, pattern)
+				continue
+			}
+			if next.IsZero() || g.Expires.Before(next) {
+				next = g.Expires
+			}
+		}
+		// Patterns are validated when granted
+		_ = l.add(pattern, d.schemes)
+	}
+	d.origins.Store(l)
+	if next.IsZero() {
+		d.nextExpiry.Store(0)
+	} else {
+		d.nextExpiry.Store(next.UnixNano())
+	}
+	select {
+	case d.changed <- struct{}{}:
+	default:
+	}
+	return expired
+}
+
+// notify reports expired grants, outside of the lock so OnExpire can use d.
+func (d *DynamicOrigins) notify(expired []OriginGrant) {
+	if d.onExpire == nil {
+		return
+	}
+	slices.SortFunc(expired, func(a, b OriginGrant) int {
+		return a.Expires.Compare(b.Expires)
+	})
+	for _, g := range expired {
+		d.onExpire(g)
+	}
+}
@@ -0,0 +1,160 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDynamicOrigins(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var expired []OriginGrant
+	d := NewDynamicOrigins(DynamicOriginsOptions{
+		Now:      clock.Now,
+		OnExpire: func(g OriginGrant) { expired = append(expired, g) },
+	})
+	ctx := context.Background()
+	assertLookups := func(want map[string]bool) {
+		t.Helper()
+		for origin, allowed := range want {
+			if got, err := d.Lookup(ctx, origin); got != allowed || err != nil {
+				t.Errorf("Lookup(%q) = %v, %v, want %v", origin, got, err, allowed)
+			}
+		}
+	}
+
+	demo := clock.now.Add(time.Hour)
+	incident := clock.now.Add(2 * time.Hour)
+	for _, err := range []error{
+		d.AllowOrigin("https://foo.com"),
+		d.AllowOriginUntil("https://demo.vendor.com", demo),
+		d.AllowOriginUntil("https://*.support.com", incident),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertLookups(map[string]bool{
+		"https://foo.com":          true,
+		"https://demo.vendor.com":  true,
+		"https://eu.support.com":   true,
+		"https://other.vendor.com": false,
+	})
+
+	clock.Advance(time.Hour)
+	assertLookups(map[string]bool{
+		"https://foo.com":         true,
+		"https://demo.vendor.com": false,
+		"https://eu.support.com":  true,
+	})
+	want := []OriginGrant{{"https://demo.vendor.com", demo}}
+	if !reflect.DeepEqual(expired, want) {
+		t.Errorf("got expired %v, want %v", expired, want)
+	}
+
+	// Renewing a grant before it expires
+	if err := d.AllowOriginUntil("https://*.support.com", incident.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(90 * time.Minute)
+	assertLookups(map[string]bool{"https://eu.support.com": true})
+
+	clock.Advance(time.Hour)
+	d.Prune()
+	want = append(want, OriginGrant{"https://*.support.com", incident.Add(time.Hour)})
+	if !reflect.DeepEqual(expired, want) {
+		t.Errorf("got expired %v, want %v", expired, want)
+	}
+	if got := d.Grants(); !reflect.DeepEqual(got, []OriginGrant{{Pattern: "https://foo.com"}}) {
+		t.Errorf("got grants %v", got)
+	}
+
+	if !d.RemoveOrigin("https://foo.com") || d.RemoveOrigin("https://foo.com") {
+		t.Error("RemoveOrigin should only report existing grants")
+	}
+	assertLookups(map[string]bool{"https://foo.com": false})
+	if len(expired) != 2 {
+		t.Errorf("removed grants should not be reported as expired")
+	}
+}
+
+func TestDynamicOriginsRun(t *testing.T) {
+	expired := make(chan OriginGrant, 2)
+	d := NewDynamicOrigins(DynamicOriginsOptions{
+		OnExpire: func(g OriginGrant) { expired <- g },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	// Grants expire without any lookup, including the ones granted while
+	// Run waits for a later expiry
+	if err := d.AllowOriginUntil("https://later.com", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AllowOriginUntil("https://demo.com", time.Now().Add(20*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case g := <-expired:
+		if g.Pattern != "https://demo.com" {
+			t.Errorf("got expired grant %v, want https://demo.com", g)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("grant not pruned on expiry")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+}
+
+func TestDynamicOriginsRejects(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1e9, 0)}
+	d := NewDynamicOrigins(DynamicOriginsOptions{Now: clock.Now})
+	if err := d.AllowOrigin("https://foo.com/path"); err == nil {
+		t.Error("invalid pattern should be rejected")
+	}
+	if err := d.AllowOrigin("*"); err == nil {
+		t.Error("match all pattern should be rejected")
+	}
+	if err := d.AllowOriginUntil("https://foo.com", clock.now); err == nil {
+		t.Error("grant expiring now should be rejected")
+	}
+	if len(d.Grants()) != 0 {
+		t.Error("rejected grants should not be added")
+	}
+}
+
+func TestDynamicOriginsOption(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1e9, 0)}
+	d := NewDynamicOrigins(DynamicOriginsOptions{Now: clock.Now})
+	h := New(Options{OriginStore: d}).Handler(testHandler)
+	allowed := func() bool {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+		req.Header.Add("Origin", "https://foo.com")
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res.Header().Get("Access-Control-Allow-Origin") == "https://foo.com"
+	}
+	if allowed() {
+		t.Error("origin allowed before the grant")
+	}
+	d.AllowOriginUntil("https://foo.com", clock.now.Add(time.Minute))
+	if !allowed() {
+		t.Error("origin not allowed during the grant")
+	}
+	clock.Advance(time.Minute)
+	if allowed() {
+		t.Error("origin allowed after the grant expired")
+	}
+}
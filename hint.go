@@ -0,0 +1,136 @@
+package cors
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Differences between a rejected origin and the nearest allowed one.
+const (
+	hintScheme        = "scheme differs"
+	hintPort          = "port differs"
+	hintWWW           = "www. prefix differs"
+	hintTrailingSlash = "trailing slash"
+)
+
+// nearest returns the entry of the list nearest to a rejected origin, along
+// with what differs, for debugging purposes. Only near misses are reported:
+// the host must be the same, except for a www. prefix.
+func (l *originList) nearest(origin string) (entry string, diffs []string, found bool) {
+	origin = strings.ToLower(origin)
+	var slash bool
+	if trimmed := strings.TrimRight(origin, "/"); trimmed != origin {
+		origin, slash = trimmed, true
+	}
+	scheme, host, port, ok := parseOrigin(origin)
+	if !ok {
+		return "", nil, false
+	}
+	if host, ok = normalizeHost(host); !ok {
+		return "", nil, false
+	}
+	explicitPort := port != defaultPort(scheme)
+
+	best := -1
+	consider := func(e string, d []string) {
+		if slash {
+			d = append(d, hintTrailingSlash)
+		}
+		if len(d) == 0 {
+			return
+		}
+		if best < 0 || len(d) < best || len(d) == best && e < entry {
+			entry, diffs, best = e, d, len(d)
+		}
+	}
+
+	for e := range l.origins {
+		es, eh, ep, ok := parseOrigin(e)
+		if !ok {
+			continue
+		}
+		var d []string
+		if es != scheme {
+			d = append(d, hintScheme)
+		}
+		switch {
+		case eh == host:
+		case eh == "www."+host || host == "www."+eh:
+			d = append(d, hintWWW)
+		default:
+			continue
+		}
+		// Default ports differ with the scheme
+		if ep != port && (explicitPort || ep != defaultPort(es)) {
+			d = append(d, hintPort)
+		}
+		consider(e, d)
+	}
+
+	// Patterns are tried against variants of the origin
+	schemes := []string{scheme}
+	switch scheme {
+	case "http":
+		schemes = append(schemes, "https")
+	case "https":
+		schemes = append(schemes, "http")
+	}
+	hosts := []string{host}
+	if h, ok := strings.CutPrefix(host, "www."); ok {
+		hosts = append(hosts, h)
+	} else {
+		hosts = append(hosts, "www."+host)
+	}
+	for _, vs := range schemes {
+		ports := []int{port}
+		if explicitPort && defaultPort(vs) != 0 {
+			ports = append(ports, defaultPort(vs))
+		} else if vs != scheme {
+			// Keep the default port of the variant scheme
+			ports = []int{defaultPort(vs)}
+		}
+		for _, vh := range hosts {
+			for _, vp := range ports {
+				var d []string
+				if vs != scheme {
+					d = append(d, hintScheme)
+				}
+				if vh != host {
+					d = append(d, hintWWW)
+				}
+				if vp != port && (explicitPort || vp != defaultPort(vs)) {
+					d = append(d, hintPort)
+				}
+				if len(d) == 0 && !slash {
+					continue
+				}
+				variant := vs + "://" + vh
+				if vp != defaultPort(vs) {
+					variant += ":" + strconv.Itoa(vp)
+				}
+				for _, w := range l.wOrigins {
+					if w.match(variant) {
+						consider(w.prefix+"*"+w.suffix, slices.Clone(d))
+					}
+				}
+				for _, p := range l.pOrigins {
+					if p.match(vs, vh, vp) {
+						consider(p.raw, slices.Clone(d))
+					}
+				}
+			}
+		}
+	}
+	return entry, diffs, best >= 0
+}
+
+// hint returns a "did you mean" hint for an origin rejected by c, or an
+// empty string if no allowed origin is near.
+func (c *Cors) hint(origin string) string {
+	entry, diffs, found := c.allowedOrigins.nearest(origin)
+	if !found {
+		return ""
+	}
+	return "did you mean '" + entry + "' (" + strings.Join(diffs, ", ") + ")?"
+}
@@ -0,0 +1,124 @@
+package cors
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOriginListNearest(t *testing.T) {
+	var l originList
+	for _, o := range []string{
+		"https://foo.com",
+		"http://localhost:3000",
+		"https://www.bar.com",
+		"https://*.baz.com",
+		"http://127.0.0.1:8000-8999",
+	} {
+		if err := l.add(o, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		origin string
+		entry  string
+		diffs  []string
+	}{
+		{"http://foo.com", "https://foo.com", []string{hintScheme}},
+		{"https://foo.com:8443", "https://foo.com", []string{hintPort}},
+		{"https://foo.com/", "https://foo.com", []string{hintTrailingSlash}},
+		{"https://www.foo.com", "https://foo.com", []string{hintWWW}},
+		{"http://www.foo.com", "https://foo.com", []string{hintScheme, hintWWW}},
+		{"http://localhost", "http://localhost:3000", []string{hintPort}},
+		{"https://localhost:3000", "http://localhost:3000", []string{hintScheme}},
+		{"https://bar.com", "https://www.bar.com", []string{hintWWW}},
+		{"http://app.baz.com", "https://*.baz.com", []string{hintScheme}},
+		{"https://app.baz.com/", "https://*.baz.com", []string{hintTrailingSlash}},
+		{"http://127.0.0.1", "", nil},
+		{"https://127.0.0.1:8080", "http://127.0.0.1:8000-8999", []string{hintScheme}},
+		{"https://other.com", "", nil},
+		{"https://evilfoo.com", "", nil},
+		{"not an origin", "", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.origin, func(t *testing.T) {
+			entry, diffs, found := l.nearest(tc.origin)
+			if found != (tc.entry != "") || entry != tc.entry || !slices.Equal(diffs, tc.diffs) {
+				t.Errorf("nearest(%q) = %q, %q, %v, want %q, %q", tc.origin, entry, diffs, found, tc.entry, tc.diffs)
+			}
+		})
+	}
+}
+
+func TestDecisionHint(t *testing.T) {
+	var got Decision
+	logger := &testLogger{buf: &bytes.Buffer{}}
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		DeniedOrigins:  []string{"http://foo.com"},
+		Logger:         logger,
+		DecisionHooks: []DecisionHook{DecisionHookFunc(func(r *http.Request, d Decision) {
+			got = d
+		})},
+		DecisionHints: true,
+	})
+	h := s.Handler(testHandler)
+	send := func(origin string) {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+		req.Header.Set("Origin", origin)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send("https://foo.com:8443")
+	const hint = "did you mean 'https://foo.com' (port differs)?"
+	if got.Hint != hint {
+		t.Errorf("got hint %q, want %q", got.Hint, hint)
+	}
+	if !strings.Contains(logger.buf.String(), "origin 'https://foo.com:8443' not allowed, "+hint) {
+		t.Errorf("hint not logged: %s", logger.buf)
+	}
+
+	// Denied origins get no hint
+	send("http://foo.com")
+	if got.Reason != ReasonOrigin || got.Hint != "" {
+		t.Errorf("got decision %+v, want a rejection without hint", got)
+	}
+}
+
+func TestDecisionHintSampled(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		Logger:         &testLogger{buf: &bytes.Buffer{}},
+		LogSampling:    LogSampling{PerOrigin: 1},
+	})
+	od := originDecision{}
+	for _, logged := range []bool{true, false} {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+		req = req.WithContext(context.WithValue(req.Context(), logSampledKey{}, logged))
+		d := s.rejectOrigin(req, Decision{Origin: "https://foo.com:8443"}, od)
+		if got := d.Hint != ""; got != logged {
+			t.Errorf("logged = %t: got hint %q", logged, d.Hint)
+		}
+	}
+}
+
+func TestDecisionHintDisabled(t *testing.T) {
+	var got Decision
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		DecisionHooks: []DecisionHook{DecisionHookFunc(func(r *http.Request, d Decision) {
+			got = d
+		})},
+		DecisionContext: true,
+	})
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Set("Origin", "https://foo.com:8443")
+	s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	if got.Reason != ReasonOrigin || got.Hint != "" {
+		t.Errorf("got decision %+v, want a rejection without hint", got)
+	}
+}
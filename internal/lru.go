@@ -0,0 +1,70 @@
+package internal
+
+import "container/list"
+
+// An LRU is a cache of bounded size evicting its least recently used
+// entries. It is not safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	size    int
+	ll      *list.List // of *lruEntry, most recently used first
+	entries map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewLRU returns an LRU holding up to size entries (at least 1).
+func NewLRU[K comparable, V any](size int) *LRU[K, V] {
+	return &LRU[K, V]{
+		size:    max(size, 1),
+		ll:      list.New(),
+		entries: map[K]*list.Element{},
+	}
+}
+
+// Get returns the value cached for key, marking it as recently used.
+func (c *LRU[K, V]) Get(key K) (value V, found bool) {
+	e, found := c.entries[key]
+	if !found {
+		return value, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry[K, V]).value, true
+}
+
+// Add caches value for key, evicting the least recently used entry if the
+// cache is full.
+func (c *LRU[K, V]) Add(key K, value V) {
+	if e, found := c.entries[key]; found {
+		e.Value.(*lruEntry[K, V]).value = value
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.ll.PushFront(&lruEntry[K, V]{key: key, value: value})
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// Remove removes the entry for key, if any.
+func (c *LRU[K, V]) Remove(key K) {
+	if e, found := c.entries[key]; found {
+		c.ll.Remove(e)
+		delete(c.entries, key)
+	}
+}
+
+// Purge removes all the entries.
+func (c *LRU[K, V]) Purge() {
+	c.ll.Init()
+	clear(c.entries)
+}
+
+// Len returns the number of entries.
+func (c *LRU[K, V]) Len() int {
+	return c.ll.Len()
+}
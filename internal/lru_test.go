@@ -0,0 +1,31 @@
+package internal
+
+import "testing"
+
+func TestLRU(t *testing.T) {
+	c := NewLRU[string, int](2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	if v, found := c.Get("a"); !found || v != 1 {
+		t.Errorf("Get(a) = %d, %t", v, found)
+	}
+	c.Add("c", 3) // evicts b, the least recently used
+	if _, found := c.Get("b"); found {
+		t.Error("b should have been evicted")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+	c.Add("a", 4)
+	if v, _ := c.Get("a"); v != 4 {
+		t.Errorf("Add should update existing entries, got %d", v)
+	}
+	c.Remove("a")
+	if _, found := c.Get("a"); found {
+		t.Error("a should have been removed")
+	}
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("Len() after Purge = %d", c.Len())
+	}
+}
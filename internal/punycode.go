@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Punycode parameters; see https://www.rfc-editor.org/rfc/rfc3492#section-5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// HostToASCII converts the Unicode labels of a host name to their ASCII
+// Compatible Encoding (ACE) form ("xn--" followed by the punycode encoding of
+// the lowercased label). ASCII labels are lowercased and kept as is.
+// It returns false if host is not valid UTF-8.
+//
+// Note: contrary to a full IDNA implementation, no Unicode normalization is
+// performed besides lowercasing.
+func HostToASCII(host string) (string, bool) {
+	if !utf8.ValidString(host) {
+		return "", false
+	}
+	if isASCII(host) {
+		return strings.ToLower(host), true
+	}
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		label = strings.ToLower(label)
+		if !isASCII(label) {
+			encoded, ok := punycodeEncode(label)
+			if !ok {
+				return "", false
+			}
+			label = "xn--" + encoded
+		}
+		labels[i] = label
+	}
+	return strings.Join(labels, "."), true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// punycodeEncode implements the encoding procedure of RFC 3492, section 6.3.
+func punycodeEncode(s string) (string, bool) {
+	runes := []rune(s)
+	out := make([]byte, 0, len(s)+8)
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	handled := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for handled < len(runes) {
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		if int(m-n) > (1<<31-1-delta)/(handled+1) {
+			return "", false // overflow
+		}
+		delta += int(m-n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := min(max(k-bias, punyTMin), punyTMax)
+				if q < t {
+					break
+				}
+				out = append(out, punycodeDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punycodeDigit(q))
+			bias = punycodeAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return string(out), true
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punycodeAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
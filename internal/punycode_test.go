@@ -0,0 +1,27 @@
+package internal
+
+import "testing"
+
+func TestHostToASCII(t *testing.T) {
+	cases := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"EXAMPLE.com", "example.com"},
+		{"bücher.de", "xn--bcher-kva.de"},
+		{"BÜCHER.de", "xn--bcher-kva.de"},
+		{"münchen.example", "xn--mnchen-3ya.example"},
+		{"日本語.jp", "xn--wgv71a119e.jp"},
+		{"www.例え.テスト", "www.xn--r8jz45g.xn--zckzah"},
+	}
+	for _, tc := range cases {
+		got, ok := HostToASCII(tc.host)
+		if !ok || got != tc.want {
+			t.Errorf("HostToASCII(%q) = %q, %t, want %q", tc.host, got, ok, tc.want)
+		}
+	}
+	if _, ok := HostToASCII("\xff.com"); ok {
+		t.Error("HostToASCII should reject invalid UTF-8")
+	}
+}
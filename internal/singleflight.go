@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"context"
+	"sync"
+)
+
+// A Singleflight de-duplicates concurrent calls for the same key: while a
+// call is in flight, callers with the same key wait for its result instead
+// of making their own call.
+// The zero value is ready to use.
+type Singleflight[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*flight[V]
+}
+
+type flight[V any] struct {
+	// Closed when the call returns
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// Do calls fn unless a call for key is already in flight, then waits for the
+// result of the call. The call runs in its own goroutine: when ctx is done
+// first, Do returns the error of ctx while the call carries on for the other
+// callers.
+func (g *Singleflight[K, V]) Do(ctx context.Context, key K, fn func() (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[K]*flight[V]{}
+	}
+	f, found := g.calls[key]
+	if !found {
+		f = &flight[V]{done: make(chan struct{})}
+		g.calls[key] = f
+		go g.call(key, f, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+func (g *Singleflight[K, V]) call(key K, f *flight[V], fn func() (V, error)) {
+	f.value, f.err = fn()
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(f.done)
+}
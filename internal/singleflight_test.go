@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleflight(t *testing.T) {
+	var g Singleflight[string, int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = g.Do(context.Background(), "key", func() (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls.Load() >= 10 {
+		t.Errorf("concurrent calls should be de-duplicated, got %d calls", calls.Load())
+	}
+	for _, r := range results {
+		if r != 42 {
+			t.Errorf("all callers should get the result, got %v", results)
+			break
+		}
+	}
+	if v, _ := g.Do(context.Background(), "key", func() (int, error) { return 1, nil }); v != 1 {
+		t.Error("calls after completion should not be de-duplicated")
+	}
+}
+
+func TestSingleflightCanceled(t *testing.T) {
+	var g Singleflight[string, int]
+	release := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// The caller returns when its context is done, without waiting for the
+	// call
+	if _, err := g.Do(ctx, "key", func() (int, error) {
+		<-release
+		return 42, nil
+	}); err != context.DeadlineExceeded {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	// The call carries on for the other callers
+	done := make(chan int)
+	go func() {
+		v, _ := g.Do(context.Background(), "key", func() (int, error) { return 1, nil })
+		done <- v
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if v := <-done; v != 42 {
+		t.Errorf("got %d, want the result of the call in flight", v)
+	}
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"container/heap"
+	"slices"
+	"strings"
+)
+
+// MaxTopKKeyLen is the length beyond which the keys of a TopK are truncated,
+// bounding the memory of keys coming from untrusted input.
+const MaxTopKKeyLen = 256
+
+// TopK estimates the most frequent keys of a stream in bounded memory, using
+// the Space-Saving algorithm: once full, a new key replaces the least
+// frequent one and inherits its count, so the counts of the reported keys are
+// over-estimated by at most the count of the evicted keys. The keys are kept
+// in a min-heap of their counts, so adding a key takes logarithmic time. It
+// is not safe for concurrent use.
+type TopK struct {
+	capacity int
+	entries  map[string]*topKEntry
+	// Min-heap of the entries by count
+	heap topKHeap
+}
+
+// A TopKEntry is a key with its estimated count.
+type TopKEntry struct {
+	Key   string
+	Count uint64
+}
+
+type topKEntry struct {
+	TopKEntry
+	// Index in the heap
+	index int
+}
+
+// NewTopK creates a TopK tracking up to capacity keys.
+func NewTopK(capacity int) *TopK {
+	capacity = max(capacity, 1)
+	return &TopK{
+		capacity: capacity,
+		entries:  make(map[string]*topKEntry, capacity),
+		heap:     make(topKHeap, 0, capacity),
+	}
+}
+
+// Add counts an occurrence of key, truncated to MaxTopKKeyLen bytes.
+func (t *TopK) Add(key string) {
+	if len(key) > MaxTopKKeyLen {
+		// Copied so the long key isn't retained
+		key = strings.Clone(strings.ToValidUTF8(key[:MaxTopKKeyLen], ""))
+	}
+	if e, found := t.entries[key]; found {
+		e.Count++
+		heap.Fix(&t.heap, e.index)
+		return
+	}
+	if len(t.heap) < t.capacity {
+		e := &topKEntry{TopKEntry: TopKEntry{Key: key, Count: 1}}
+		t.entries[key] = e
+		heap.Push(&t.heap, e)
+		return
+	}
+	// Replace the least frequent key
+	e := t.heap[0]
+	delete(t.entries, e.Key)
+	e.Key = key
+	e.Count++
+	t.entries[key] = e
+	heap.Fix(&t.heap, 0)
+}
+
+// Top returns up to k keys, most frequent first.
+func (t *TopK) Top(k int) []TopKEntry {
+	entries := make([]TopKEntry, 0, len(t.heap))
+	for _, e := range t.heap {
+		entries = append(entries, e.TopKEntry)
+	}
+	slices.SortFunc(entries, func(a, b TopKEntry) int {
+		if a.Count != b.Count {
+			if a.Count > b.Count {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return entries[:min(k, len(entries))]
+}
+
+// topKHeap implements heap.Interface.
+type topKHeap []*topKEntry
+
+func (h topKHeap) Len() int { return len(h) }
+
+func (h topKHeap) Less(i, j int) bool {
+	if h[i].Count != h[j].Count {
+		return h[i].Count < h[j].Count
+	}
+	return h[i].Key < h[j].Key
+}
+
+func (h topKHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *topKHeap) Push(x any) {
+	e := x.(*topKEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *topKHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTopK(t *testing.T) {
+	tk := NewTopK(3)
+	for i := 0; i < 100; i++ {
+		tk.Add("a")
+	}
+	for i := 0; i < 50; i++ {
+		tk.Add("b")
+	}
+	tk.Add("c")
+	want := []TopKEntry{{"a", 100}, {"b", 50}}
+	if got := tk.Top(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("Top(2) = %v, want %v", got, want)
+	}
+
+	// A flood of distinct keys only ever replaces the least frequent one
+	for i := 0; i < 40; i++ {
+		tk.Add("flood" + strconv.Itoa(i))
+	}
+	if len(tk.entries) != 3 {
+		t.Errorf("got %d tracked keys, want 3", len(tk.entries))
+	}
+	if got := tk.Top(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("Top(2) = %v after the flood, want %v", got, want)
+	}
+	top := tk.Top(10)
+	if len(top) != 3 {
+		t.Fatalf("Top(10) returned %d entries, want 3", len(top))
+	}
+	// The last key inherited the counts of the evicted ones
+	if top[2] != (TopKEntry{"flood39", 41}) {
+		t.Errorf("got %v, want {flood39 41}", top[2])
+	}
+}
+
+func TestTopKLongKey(t *testing.T) {
+	tk := NewTopK(3)
+	long := strings.Repeat("a", 10*MaxTopKKeyLen)
+	tk.Add(long)
+	tk.Add(long + "b")
+	want := []TopKEntry{{long[:MaxTopKKeyLen], 2}}
+	if got := tk.Top(3); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d entries, want keys truncated to %d bytes", len(got), MaxTopKKeyLen)
+	}
+}
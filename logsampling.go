@@ -0,0 +1,176 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LogSampling bounds the number of requests whose debug log messages are
+// written, so clients sending many requests, like scanners, can't flood the
+// logs. The decision is taken once per request, which is logged either with
+// all its messages or not at all.
+type LogSampling struct {
+	// PerOrigin is the maximum number of requests logged per origin and
+	// interval. Sampling is disabled when 0.
+	PerOrigin int
+	// Interval over which requests are counted.
+	// Default value is 1 minute.
+	Interval time.Duration
+	// MaxOrigins bounds the number of origins counted per interval: the
+	// requests from further origins share a single PerOrigin budget.
+	// Default value is 1000.
+	MaxOrigins int
+}
+
+// logSampler enforces a LogSampling. The summary of the requests suppressed
+// during an interval is logged when it ends, or with the first request of a
+// later interval if that comes first.
+type logSampler struct {
+	perOrigin  int
+	interval   time.Duration
+	maxOrigins int
+	now        func() time.Time
+	// Schedules the summary of an interval
+	afterFunc func(d time.Duration, f func())
+	logf      func(format string, a ...any)
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+	// Count of the requests from origins beyond maxOrigins
+	others     int
+	suppressed int
+	// Whether the summary of the current interval is scheduled
+	scheduled bool
+}
+
+// logSummary describes the requests suppressed during an interval.
+type logSummary struct {
+	suppressed int
+	origins    int
+	interval   time.Duration
+}
+
+func newLogSampler(s LogSampling, logf func(format string, a ...any)) *logSampler {
+	if s.PerOrigin <= 0 {
+		return nil
+	}
+	ls := &logSampler{
+		perOrigin:  s.PerOrigin,
+		interval:   s.Interval,
+		maxOrigins: s.MaxOrigins,
+		now:        time.Now,
+		afterFunc:  func(d time.Duration, f func()) { time.AfterFunc(d, f) },
+		logf:       logf,
+		counts:     map[string]int{},
+	}
+	if ls.interval <= 0 {
+		ls.interval = time.Minute
+	}
+	if ls.maxOrigins <= 0 {
+		ls.maxOrigins = 1000
+	}
+	return ls
+}
+
+// allow reports whether a request from origin may be logged.
+func (ls *logSampler) allow(origin string) bool {
+	ls.mu.Lock()
+	now := ls.now()
+	summary := ls.rollover(now)
+	count, found := ls.counts[origin]
+	switch {
+	case found || len(ls.counts) < ls.maxOrigins:
+		count++
+		ls.counts[origin] = count
+	default:
+		ls.others++
+		count = ls.others
+	}
+	ok := count <= ls.perOrigin
+	if !ok {
+		ls.suppressed++
+		if !ls.scheduled {
+			ls.scheduled = true
+			ls.afterFunc(ls.start.Add(ls.interval).Sub(now), ls.summarize)
+		}
+	}
+	ls.mu.Unlock()
+	ls.log(summary)
+	return ok
+}
+
+// summarize ends the current interval if it is over, logging its summary.
+func (ls *logSampler) summarize() {
+	ls.mu.Lock()
+	summary := ls.rollover(ls.now())
+	ls.mu.Unlock()
+	ls.log(summary)
+}
+
+// rollover starts a new interval at now if the current one is over,
+// returning the summary of the current one if requests were suppressed. It
+// must be called with mu held.
+func (ls *logSampler) rollover(now time.Time) (summary *logSummary) {
+	if now.Sub(ls.start) < ls.interval {
+		return nil
+	}
+	if ls.suppressed > 0 {
+		summary = &logSummary{suppressed: ls.suppressed, origins: ls.suppressedOrigins(), interval: ls.interval}
+	}
+	ls.start = now
+	clear(ls.counts)
+	ls.others = 0
+	ls.suppressed = 0
+	ls.scheduled = false
+	return summary
+}
+
+func (ls *logSampler) log(summary *logSummary) {
+	if summary != nil {
+		ls.logf("Suppressed the logs of %d requests from %d origins in the last %v",
+			summary.suppressed, summary.origins, summary.interval)
+	}
+}
+
+// suppressedOrigins returns the number of origins with suppressed requests,
+// the origins beyond maxOrigins counting as one. It must be called with mu
+// held.
+func (ls *logSampler) suppressedOrigins() int {
+	n := 0
+	for _, count := range ls.counts {
+		if count > ls.perOrigin {
+			n++
+		}
+	}
+	if ls.others > ls.perOrigin {
+		n++
+	}
+	return n
+}
+
+type logSampledKey struct{}
+
+// sampleLogs decides whether the messages about r are logged, returning r
+// with the decision attached to its context when logs are sampled.
+func (c *Cors) sampleLogs(r *http.Request) *http.Request {
+	if c.Log == nil || c.logSampler == nil {
+		return r
+	}
+	ok := c.logSampler.allow(r.Header.Get("Origin"))
+	return r.WithContext(context.WithValue(r.Context(), logSampledKey{}, ok))
+}
+
+// logged reports whether the messages about r are logged. Requests which
+// didn't go through sampleLogs are sampled on each message.
+func (c *Cors) logged(r *http.Request) bool {
+	if c.logSampler == nil {
+		return true
+	}
+	if ok, found := r.Context().Value(logSampledKey{}).(bool); found {
+		return ok
+	}
+	return c.logSampler.allow(r.Header.Get("Origin"))
+}
@@ -0,0 +1,102 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogSampling(t *testing.T) {
+	logger := &linesLogger{}
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		Logger:         logger,
+		LogSampling:    LogSampling{PerOrigin: 2, Interval: time.Minute, MaxOrigins: 2},
+	})
+	clock := &fakeClock{now: time.Unix(1e9, 0)}
+	s.logSampler.now = clock.Now
+	var summaries []func()
+	var delays []time.Duration
+	s.logSampler.afterFunc = func(d time.Duration, f func()) {
+		delays = append(delays, d)
+		summaries = append(summaries, f)
+	}
+	h := s.Handler(testHandler)
+	send := func(origin string, n int) {
+		for i := 0; i < n; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+			req.Header.Add("Origin", origin)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}
+	lines := func() []string {
+		defer logger.Reset()
+		return logger.lines
+	}
+
+	// Each request logs 2 messages, all or none of them
+	send("https://evil.com", 10)
+	if got := lines(); len(got) != 4 {
+		t.Errorf("got %d lines, want 4: %q", len(got), got)
+	}
+	send("https://foo.com", 1)
+	if got := lines(); len(got) != 2 {
+		t.Errorf("other origins should have their own budget, got %q", got)
+	}
+	// Origins beyond MaxOrigins share a budget
+	send("https://a.com", 1)
+	send("https://b.com", 1)
+	send("https://c.com", 1)
+	if got := lines(); len(got) != 4 {
+		t.Errorf("got %d lines for origins beyond MaxOrigins, want 4: %q", len(got), got)
+	}
+
+	// The summary is logged at the end of the interval, without traffic
+	if len(summaries) != 1 || delays[0] != time.Minute {
+		t.Fatalf("got %d summaries scheduled after %v, want 1 after 1m0s", len(summaries), delays)
+	}
+	clock.Advance(time.Minute)
+	summaries[0]()
+	want := []string{"Suppressed the logs of 9 requests from 2 origins in the last 1m0s"}
+	if got := lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	send("https://evil.com", 1)
+	if got := lines(); len(got) != 2 {
+		t.Errorf("no summary expected without suppressed requests, got %q", got)
+	}
+
+	// The first request of a later interval logs the summary if it comes
+	// before the scheduled one, which is then skipped
+	send("https://evil.com", 2)
+	clock.Advance(time.Minute)
+	send("https://evil.com", 1)
+	if got := lines(); len(got) != 5 || got[2] != "Suppressed the logs of 1 requests from 1 origins in the last 1m0s" {
+		t.Errorf("got %q, want the logs of a request, a summary and the logs of a request", got)
+	}
+	summaries[len(summaries)-1]()
+	if got := lines(); len(got) != 0 {
+		t.Errorf("got %q, want no summary", got)
+	}
+}
+
+func TestLogSamplingDisabled(t *testing.T) {
+	if s := New(Options{LogSampling: LogSampling{Interval: time.Second}}); s.logSampler != nil {
+		t.Error("sampling should be disabled without PerOrigin")
+	}
+}
+
+type linesLogger struct {
+	lines []string
+}
+
+func (l *linesLogger) Printf(format string, v ...any) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func (l *linesLogger) Reset() {
+	l.lines = nil
+}
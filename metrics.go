@@ -0,0 +1,119 @@
+package cors
+
+import (
+	"expvar"
+	"net/http"
+	"sync"
+
+	"github.com/rs/cors/internal"
+)
+
+// MetricsOptions configures Metrics.
+type MetricsOptions struct {
+	// Name under which the metrics are published with expvar. The metrics
+	// are not published when empty. As with expvar.Publish, NewMetrics
+	// panics if the name is already used.
+	Name string
+	// TopRejectedOrigins is the number of most rejected origins reported.
+	// Default value is 10.
+	TopRejectedOrigins int
+}
+
+// Metrics is a DecisionHook counting CORS requests by kind (preflight or
+// actual), by outcome (allowed or rejected), and rejections by reason; see
+// Options.DecisionHooks. The most rejected origins are tracked in bounded
+// memory, so origins forged by attackers can't grow it: their number is
+// bounded, and origins longer than 256 bytes are truncated. Requests without
+// Origin header, which aren't CORS requests, are not counted.
+//
+// Metrics is an expvar.Var whose value is a JSON object like:
+//
+//	{
+//	  "preflight": 12, "actual": 340,
+//	  "allowed": 348, "rejected": 4,
+//	  "rejections": {"origin": 3, "method": 1},
+//	  "top_rejected_origins": [{"origin": "https://evil.com", "count": 3}]
+//	}
+type Metrics struct {
+	preflight  expvar.Int
+	actual     expvar.Int
+	allowed    expvar.Int
+	rejected   expvar.Int
+	rejections expvar.Map
+	vars       expvar.Map
+
+	topN int
+	mu   sync.Mutex
+	top  *internal.TopK
+}
+
+// NewMetrics creates Metrics, publishing them with expvar when
+// options.Name is set.
+func NewMetrics(options MetricsOptions) *Metrics {
+	m := &Metrics{topN: options.TopRejectedOrigins}
+	if m.topN <= 0 {
+		m.topN = 10
+	}
+	// Track more origins than reported for accurate estimations
+	m.top = internal.NewTopK(m.topN * 8)
+	m.vars.Set("preflight", &m.preflight)
+	m.vars.Set("actual", &m.actual)
+	m.vars.Set("allowed", &m.allowed)
+	m.vars.Set("rejected", &m.rejected)
+	m.vars.Set("rejections", &m.rejections)
+	m.vars.Set("top_rejected_origins", expvar.Func(func() any {
+		return m.TopRejectedOrigins()
+	}))
+	if options.Name != "" {
+		expvar.Publish(options.Name, m)
+	}
+	return m
+}
+
+// OnDecision counts d.
+func (m *Metrics) OnDecision(r *http.Request, d Decision) {
+	if d.Origin == "" {
+		return
+	}
+	if d.Preflight {
+		m.preflight.Add(1)
+	} else {
+		m.actual.Add(1)
+	}
+	if d.Allowed {
+		m.allowed.Add(1)
+		return
+	}
+	m.rejected.Add(1)
+	m.rejections.Add(d.Reason, 1)
+	if d.Reason == ReasonOrigin {
+		m.mu.Lock()
+		m.top.Add(d.Origin)
+		m.mu.Unlock()
+	}
+}
+
+// A RejectedOrigin is an origin with its estimated number of rejections.
+type RejectedOrigin struct {
+	Origin string `json:"origin"`
+	Count  uint64 `json:"count"`
+}
+
+// TopRejectedOrigins returns the origins rejected the most, most rejected
+// first. Counts are estimations, which may exceed the actual counts when
+// many distinct origins are rejected.
+func (m *Metrics) TopRejectedOrigins() []RejectedOrigin {
+	m.mu.Lock()
+	entries := m.top.Top(m.topN)
+	m.mu.Unlock()
+	origins := make([]RejectedOrigin, len(entries))
+	for i, e := range entries {
+		origins[i] = RejectedOrigin{Origin: e.Key, Count: e.Count}
+	}
+	return origins
+}
+
+// String returns the metrics as JSON, implementing expvar.Var.
+func (m *Metrics) String() string {
+	return m.vars.String()
+}
@@ -0,0 +1,132 @@
+// Package cors/metrics/prometheus provides a Prometheus collector of the
+// decisions taken by a github.com/rs/cors handler.
+package prometheus
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/cors"
+	"github.com/rs/cors/internal"
+)
+
+// OtherOrigin is the origin label of the rejections from the origins beyond
+// Options.MaxOrigins.
+const OtherOrigin = "other"
+
+// Options configures a Collector.
+type Options struct {
+	// Namespace prefixes the metric names, i.e. "myapp" for
+	// myapp_cors_requests_total.
+	Namespace string
+	// MaxOrigins bounds the number of distinct origin labels of
+	// cors_rejected_origins_total: the rejections from further origins are
+	// counted with the OtherOrigin label, so origins forged by attackers
+	// can't grow the cardinality.
+	// Default value is 100.
+	MaxOrigins int
+}
+
+// Collector is a prometheus.Collector counting the decisions taken by a
+// cors.Cors handler, which it is attached to as a cors.DecisionHook:
+//
+//	c := prometheus.NewCollector(prometheus.Options{})
+//	registry.MustRegister(c)
+//	handler := cors.New(cors.Options{DecisionHooks: []cors.DecisionHook{c}})
+//
+// It exposes:
+//   - cors_requests_total{kind,outcome,reason}: the CORS requests by kind
+//     (preflight or actual), outcome (allowed or rejected) and rejection
+//     reason (empty when allowed).
+//   - cors_rejected_origins_total{origin}: the requests rejected because of
+//     their origin.
+//
+// Requests without Origin header, which aren't CORS requests, are not
+// counted.
+type Collector struct {
+	requests        *prom.CounterVec
+	rejectedOrigins *prom.CounterVec
+	maxOrigins      int
+
+	mu      sync.Mutex
+	origins map[string]prom.Counter
+}
+
+var _ cors.DecisionHook = (*Collector)(nil)
+
+// NewCollector creates a Collector.
+func NewCollector(options Options) *Collector {
+	c := &Collector{
+		requests: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: options.Namespace,
+			Name:      "cors_requests_total",
+			Help:      "CORS requests by kind, outcome and rejection reason.",
+		}, []string{"kind", "outcome", "reason"}),
+		rejectedOrigins: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: options.Namespace,
+			Name:      "cors_rejected_origins_total",
+			Help:      "CORS requests rejected because of their origin, by origin.",
+		}, []string{"origin"}),
+		maxOrigins: options.MaxOrigins,
+		origins:    map[string]prom.Counter{},
+	}
+	if c.maxOrigins <= 0 {
+		c.maxOrigins = 100
+	}
+	return c
+}
+
+// OnDecision counts d.
+func (c *Collector) OnDecision(r *http.Request, d cors.Decision) {
+	if d.Origin == "" {
+		return
+	}
+	kind, outcome := "actual", "allowed"
+	if d.Preflight {
+		kind = "preflight"
+	}
+	if !d.Allowed {
+		outcome = "rejected"
+	}
+	c.requests.WithLabelValues(kind, outcome, d.Reason).Inc()
+	if d.Reason == cors.ReasonOrigin {
+		c.originCounter(d.Origin).Inc()
+	}
+}
+
+// originCounter returns the counter of origin, or the one of OtherOrigin
+// once MaxOrigins origins are counted. As for the TopK keys, origin is
+// truncated to internal.MaxTopKKeyLen bytes, and made valid UTF-8 since
+// Prometheus rejects other label values.
+func (c *Collector) originCounter(origin string) prom.Counter {
+	if len(origin) > internal.MaxTopKKeyLen {
+		// Copied so the long origin isn't retained
+		origin = strings.Clone(origin[:internal.MaxTopKKeyLen])
+	}
+	origin = strings.ToValidUTF8(origin, "")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if counter, found := c.origins[origin]; found {
+		return counter
+	}
+	if len(c.origins) >= c.maxOrigins {
+		return c.rejectedOrigins.WithLabelValues(OtherOrigin)
+	}
+	counter := c.rejectedOrigins.WithLabelValues(origin)
+	c.origins[origin] = counter
+	return counter
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	c.requests.Describe(ch)
+	c.rejectedOrigins.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	c.requests.Collect(ch)
+	c.rejectedOrigins.Collect(ch)
+}
@@ -0,0 +1,80 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/cors"
+	"github.com/rs/cors/internal"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector(Options{MaxOrigins: 2})
+	h := cors.New(cors.Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		DecisionHooks:  []cors.DecisionHook{c},
+	}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(method string, headers http.Header) {
+		req, _ := http.NewRequest(method, "http://example.com/foo", nil)
+		req.Header = headers
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send(http.MethodGet, http.Header{})
+	send(http.MethodGet, http.Header{"Origin": {"https://foo.com"}})
+	send(http.MethodOptions, http.Header{"Origin": {"https://foo.com"}, "Access-Control-Request-Method": {http.MethodGet}})
+	send(http.MethodOptions, http.Header{"Origin": {"https://foo.com"}, "Access-Control-Request-Method": {http.MethodDelete}})
+	for i := 0; i < 3; i++ {
+		send(http.MethodGet, http.Header{"Origin": {"https://evil.com"}})
+	}
+	for i := 0; i < 4; i++ {
+		send(http.MethodGet, http.Header{"Origin": {fmt.Sprintf("https://random%d.com", i)}})
+	}
+
+	expected := `
+# HELP cors_rejected_origins_total CORS requests rejected because of their origin, by origin.
+# TYPE cors_rejected_origins_total counter
+cors_rejected_origins_total{origin="https://evil.com"} 3
+cors_rejected_origins_total{origin="https://random0.com"} 1
+cors_rejected_origins_total{origin="other"} 3
+# HELP cors_requests_total CORS requests by kind, outcome and rejection reason.
+# TYPE cors_requests_total counter
+cors_requests_total{kind="actual",outcome="allowed",reason=""} 1
+cors_requests_total{kind="actual",outcome="rejected",reason="origin"} 7
+cors_requests_total{kind="preflight",outcome="allowed",reason=""} 1
+cors_requests_total{kind="preflight",outcome="rejected",reason="method"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectorNamespace(t *testing.T) {
+	c := NewCollector(Options{Namespace: "myapp"})
+	c.OnDecision(nil, cors.Decision{Origin: "https://foo.com", Allowed: true})
+	if n := testutil.CollectAndCount(c, "myapp_cors_requests_total"); n != 1 {
+		t.Errorf("got %d myapp_cors_requests_total series, want 1", n)
+	}
+}
+
+func TestCollectorSanitizesOrigins(t *testing.T) {
+	c := NewCollector(Options{})
+	long := "https://" + strings.Repeat("a", 2*internal.MaxTopKKeyLen) + ".com"
+	for _, origin := range []string{"https://\xffevil.com", long} {
+		c.OnDecision(nil, cors.Decision{Origin: origin, Reason: cors.ReasonOrigin})
+	}
+
+	expected := fmt.Sprintf(`
+# HELP cors_rejected_origins_total CORS requests rejected because of their origin, by origin.
+# TYPE cors_rejected_origins_total counter
+cors_rejected_origins_total{origin="%s"} 1
+cors_rejected_origins_total{origin="https://evil.com"} 1
+`, long[:internal.MaxTopKKeyLen])
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "cors_rejected_origins_total"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,85 @@
+package cors
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// metricsRuns numbers the runs of TestMetrics, as expvar.Publish panics when
+// a name is published twice, i.e. with -count.
+var metricsRuns atomic.Int32
+
+func TestMetrics(t *testing.T) {
+	name := fmt.Sprintf("cors_test_metrics_%d", metricsRuns.Add(1))
+	m := NewMetrics(MetricsOptions{Name: name, TopRejectedOrigins: 2})
+	s := New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		AllowedHeaders: []string{"X-Foo"},
+		DecisionHooks:  []DecisionHook{m},
+	})
+	h := s.Handler(testHandler)
+
+	send := func(method string, headers http.Header) {
+		req, _ := http.NewRequest(method, "http://example.com/foo", nil)
+		req.Header = headers
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send(http.MethodGet, http.Header{})
+	send(http.MethodGet, http.Header{"Origin": {"https://foo.com"}})
+	send(http.MethodDelete, http.Header{"Origin": {"https://foo.com"}})
+	send(http.MethodOptions, http.Header{"Origin": {"https://foo.com"}, "Access-Control-Request-Method": {http.MethodGet}})
+	send(http.MethodOptions, http.Header{"Origin": {"https://foo.com"}, "Access-Control-Request-Method": {http.MethodGet}, "Access-Control-Request-Headers": {"x-bar"}})
+	send(http.MethodOptions, http.Header{"Origin": {"https://foo.com"}, "Access-Control-Request-Method": {http.MethodGet}, "Access-Control-Request-Private-Network": {"true"}})
+	for i := 0; i < 3; i++ {
+		send(http.MethodGet, http.Header{"Origin": {"https://evil.com"}})
+	}
+	send(http.MethodGet, http.Header{"Origin": {"https://bad.com"}})
+	for i := 0; i < 5; i++ {
+		send(http.MethodGet, http.Header{"Origin": {fmt.Sprintf("https://random%d.com", i)}})
+	}
+
+	var got struct {
+		Preflight          int
+		Actual             int
+		Allowed            int
+		Rejected           int
+		Rejections         map[string]int
+		TopRejectedOrigins []RejectedOrigin `json:"top_rejected_origins"`
+	}
+	published := expvar.Get(name)
+	if published == nil {
+		t.Fatal("metrics not published")
+	}
+	if err := json.Unmarshal([]byte(published.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Preflight != 3 || got.Actual != 11 || got.Allowed != 2 || got.Rejected != 12 {
+		t.Errorf("got counters %+v", got)
+	}
+	wantReasons := map[string]int{
+		ReasonOrigin:         9,
+		ReasonMethod:         1,
+		ReasonHeaders:        1,
+		ReasonPrivateNetwork: 1,
+	}
+	if !reflect.DeepEqual(got.Rejections, wantReasons) {
+		t.Errorf("got rejections %v, want %v", got.Rejections, wantReasons)
+	}
+	if len(got.TopRejectedOrigins) != 2 || got.TopRejectedOrigins[0] != (RejectedOrigin{"https://evil.com", 3}) {
+		t.Errorf("got top rejected origins %v", got.TopRejectedOrigins)
+	}
+}
+
+func TestMetricsUnpublished(t *testing.T) {
+	m := NewMetrics(MetricsOptions{})
+	m.OnDecision(nil, Decision{Origin: "https://foo.com", Reason: ReasonOrigin})
+	if top := m.TopRejectedOrigins(); len(top) != 1 || top[0].Count != 1 {
+		t.Errorf("got top rejected origins %v", top)
+	}
+}
@@ -0,0 +1,492 @@
+package cors
+
+import (
+	"errors"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/cors/internal"
+)
+
+// parseOrigin splits a serialized origin (scheme "://" host [ ":" port ]) into
+// its components. When the port is omitted, the default port of the scheme is
+// returned (0 for schemes without a well-known default port).
+// It does not allocate.
+func parseOrigin(s string) (scheme, host string, port int, ok bool) {
+	scheme, host, portStr, ok := splitOrigin(s)
+	if !ok {
+		return "", "", 0, false
+	}
+	if portStr == "" {
+		return scheme, host, defaultPort(scheme), true
+	}
+	port, ok = parsePort(portStr)
+	if !ok {
+		return "", "", 0, false
+	}
+	return scheme, host, port, true
+}
+
+// splitOrigin splits a serialized origin into its scheme, host and port
+// parts. The port part is empty when the origin has no explicit port.
+func splitOrigin(s string) (scheme, host, port string, ok bool) {
+	scheme, rest, found := strings.Cut(s, "://")
+	if !found || scheme == "" || rest == "" || strings.ContainsAny(rest, "/?#@") {
+		return "", "", "", false
+	}
+	host, port, hasPort := splitHostPort(rest)
+	if host == "" || strings.IndexByte(host, '*') >= 0 || (hasPort && port == "") {
+		return "", "", "", false
+	}
+	return scheme, host, port, true
+}
+
+// normalizeOrigin returns the canonical serialization of an origin: lowercase
+// scheme and host, Unicode host names converted to their punycode form,
+// canonical IPv6 literals and no explicit default port. The origin is returned
+// as is, without allocating, when it is already in canonical form.
+func normalizeOrigin(s string) (string, bool) {
+	scheme, host, portStr, ok := splitOrigin(s)
+	if !ok {
+		return "", false
+	}
+	lscheme := strings.ToLower(scheme)
+	nhost, ok := normalizeHost(host)
+	if !ok {
+		return "", false
+	}
+	port := defaultPort(lscheme)
+	if portStr != "" {
+		if port, ok = parsePort(portStr); !ok {
+			return "", false
+		}
+	}
+	keepPort := port != defaultPort(lscheme)
+	if lscheme == scheme && nhost == host && (portStr == "" || keepPort && portStr[0] != '0') {
+		return s, true
+	}
+	if !keepPort {
+		return lscheme + "://" + nhost, true
+	}
+	return lscheme + "://" + nhost + ":" + strconv.Itoa(port), true
+}
+
+// normalizeHost lowercases host, converts Unicode host names to their
+// punycode form and canonicalizes bracketed IPv6 literals.
+func normalizeHost(host string) (string, bool) {
+	if strings.HasPrefix(host, "[") {
+		if !strings.HasSuffix(host, "]") {
+			return "", false
+		}
+		addr, err := netip.ParseAddr(host[1 : len(host)-1])
+		if err != nil || !addr.Is6() || addr.Zone() != "" {
+			return "", false
+		}
+		var buf [64]byte
+		b := addr.AppendTo(buf[:0])
+		if string(b) == host[1:len(host)-1] {
+			return host, true
+		}
+		return "[" + string(b) + "]", true
+	}
+	return internal.HostToASCII(host)
+}
+
+// normalizeHostSuffix normalizes the part of a host following a wildcard.
+// As the first label may be partial, only the complete labels following it are
+// converted to their punycode form.
+func normalizeHostSuffix(suffix string) string {
+	suffix = strings.ToLower(suffix)
+	i := strings.IndexByte(suffix, '.')
+	if i < 0 {
+		return suffix
+	}
+	if labels, ok := internal.HostToASCII(suffix[i+1:]); ok {
+		return suffix[:i+1] + labels
+	}
+	return suffix
+}
+
+// normalizeWildcard normalizes the host and port parts of a wildcard origin
+// the same way normalizeOrigin does for request origins.
+func normalizeWildcard(w wildcard) wildcard {
+	w.prefix = strings.ToLower(w.prefix)
+	hostSuffix, port, hasPort := strings.Cut(strings.ToLower(w.suffix), ":")
+	w.suffix = normalizeHostSuffix(hostSuffix)
+	scheme, _, _ := strings.Cut(w.prefix, "://")
+	if hasPort && (defaultPort(scheme) == 0 || port != strconv.Itoa(defaultPort(scheme))) {
+		w.suffix += ":" + port
+	}
+	return w
+}
+
+// splitHostPort splits an authority into host and port. IPv6 literals keep
+// their enclosing brackets. hasPort is true when a colon separates the host
+// from a (possibly empty or malformed) port.
+func splitHostPort(authority string) (host, port string, hasPort bool) {
+	if strings.HasPrefix(authority, "[") {
+		i := strings.IndexByte(authority, ']')
+		if i < 0 {
+			return "", "", false
+		}
+		host, rest := authority[:i+1], authority[i+1:]
+		if rest == "" {
+			return host, "", false
+		}
+		if rest[0] != ':' {
+			return "", "", false
+		}
+		return host, rest[1:], true
+	}
+	host, port, hasPort = strings.Cut(authority, ":")
+	if strings.IndexByte(port, ':') >= 0 {
+		return "", "", false
+	}
+	return host, port, hasPort
+}
+
+// parsePort parses a decimal port number in the range [1, 65535].
+func parsePort(s string) (int, bool) {
+	if s == "" || len(s) > 5 {
+		return 0, false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	if n < 1 || n > 65535 {
+		return 0, false
+	}
+	return n, true
+}
+
+// defaultPort returns the default port of well-known origin schemes.
+func defaultPort(scheme string) int {
+	switch scheme {
+	case "http":
+		return 80
+	case "https":
+		return 443
+	}
+	return 0
+}
+
+// originPattern is an allowed origin whose port is a wildcard or a range of
+// ports (i.e.: http://localhost:* or http://127.0.0.1:3000-3999). Contrary to
+// wildcard, it is matched against the components of the parsed Origin so the
+// port part can only ever match a valid port number.
+type originPattern struct {
+	scheme string
+	// host is matched as a wildcard when hostWildcard is set, and must
+	// otherwise be equal to host.prefix. It is ignored when addrs is valid.
+	host         wildcard
+	hostWildcard bool
+	// addrs, when valid, matches origins whose host is an IP address within
+	// the prefix. Host names are never matched.
+	addrs   netip.Prefix
+	minPort int
+	maxPort int
+	// Entry the pattern was parsed from, for display purposes
+	raw string
+}
+
+// parseOriginPattern parses an origin whose port is either "*" or a range of
+// ports like "3000-3999". The host may contain a single wildcard. It returns
+// false if s is not such a pattern.
+func parseOriginPattern(s string) (originPattern, bool) {
+	scheme, rest, found := strings.Cut(s, "://")
+	if !found || scheme == "" || strings.ContainsAny(scheme, "*") || strings.ContainsAny(rest, "/?#@") {
+		return originPattern{}, false
+	}
+	host, portSpec, hasPort := splitHostPort(rest)
+	if !hasPort || host == "" || strings.Count(host, "*") > 1 {
+		return originPattern{}, false
+	}
+	p := originPattern{scheme: scheme}
+	if portSpec != "*" && strings.IndexByte(portSpec, '-') < 0 {
+		return originPattern{}, false
+	}
+	if !p.parsePorts(portSpec) {
+		return originPattern{}, false
+	}
+	if prefix, suffix, ok := strings.Cut(host, "*"); ok {
+		p.host = wildcard{strings.ToLower(prefix), normalizeHostSuffix(suffix)}
+		p.hostWildcard = true
+	} else if nhost, ok := normalizeHost(host); ok {
+		p.host = wildcard{prefix: nhost}
+	} else {
+		return originPattern{}, false
+	}
+	return p, true
+}
+
+// parsePrefixPattern parses an origin whose host is an IP prefix in CIDR
+// notation (i.e.: http://10.0.0.0/8 or https://[fd00::]/8:*). The prefix may be
+// followed by a port, a port wildcard or a range of ports; without port, only
+// the default port of the scheme matches. It returns errNotPrefix if the host
+// of s is not an IP address followed by a prefix length.
+func parsePrefixPattern(s string) (originPattern, error) {
+	scheme, rest, _ := strings.Cut(s, "://")
+	addr, rest, found := strings.Cut(rest, "/")
+	if !found {
+		return originPattern{}, errNotPrefix
+	}
+	if bracketed, ok := strings.CutPrefix(addr, "["); ok {
+		addr, ok = strings.CutSuffix(bracketed, "]")
+		if !ok {
+			return originPattern{}, errNotPrefix
+		}
+	}
+	if _, err := netip.ParseAddr(addr); err != nil {
+		return originPattern{}, errNotPrefix
+	}
+	bits, portSpec, hasPort := strings.Cut(rest, ":")
+	prefix, err := netip.ParsePrefix(addr + "/" + bits)
+	if err != nil {
+		return originPattern{}, errors.New("invalid IP prefix")
+	}
+	if prefix != prefix.Masked() {
+		return originPattern{}, errors.New("IP prefix has bits set beyond its length, use " + prefix.Masked().String())
+	}
+	p := originPattern{scheme: scheme, addrs: prefix}
+	if !hasPort {
+		p.minPort, p.maxPort = defaultPort(scheme), defaultPort(scheme)
+	} else if !p.parsePorts(portSpec) {
+		return originPattern{}, errors.New("invalid port, port wildcard or port range")
+	}
+	return p, nil
+}
+
+var errNotPrefix = errors.New("not an IP prefix")
+
+// parsePorts sets the range of ports matched by the pattern from a port, a
+// port wildcard or a range of ports.
+func (p *originPattern) parsePorts(portSpec string) bool {
+	if portSpec == "*" {
+		p.minPort, p.maxPort = 0, 65535
+		return true
+	}
+	lo, hi, isRange := strings.Cut(portSpec, "-")
+	if !isRange {
+		hi = lo
+	}
+	var ok1, ok2 bool
+	p.minPort, ok1 = parsePort(lo)
+	p.maxPort, ok2 = parsePort(hi)
+	return ok1 && ok2 && p.minPort <= p.maxPort
+}
+
+// match reports whether the given origin components match the pattern.
+// A port wildcard also matches an origin using the default port of its scheme.
+func (p originPattern) match(scheme, host string, port int) bool {
+	if scheme != p.scheme || port < p.minPort || port > p.maxPort {
+		return false
+	}
+	if p.addrs.IsValid() {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		addr, err := netip.ParseAddr(host)
+		return err == nil && addr.Zone() == "" && p.addrs.Contains(addr)
+	}
+	if p.hostWildcard {
+		return p.host.match(host)
+	}
+	return host == p.host.prefix
+}
+
+// defaultSchemes are the schemes an origin without scheme expands to.
+var defaultSchemes = []string{"http", "https"}
+
+// originList is a compiled list of origins.
+type originList struct {
+	// Normalized set of plain origins
+	origins map[string]struct{}
+	// List of origins containing wildcards
+	wOrigins []wildcard
+	// List of origins with a port wildcard or port range
+	pOrigins []originPattern
+}
+
+// add parses an origin entry and adds it to the list. Besides fully
+// qualified origins (scheme://host[:port]), entries may omit the scheme, either
+// as a bare host (example.com) or as a scheme relative authority
+// (//example.com:8443); such entries are expanded for each of the given
+// schemes. Ambiguous or malformed entries are rejected with an error.
+func (l *originList) add(entry string, schemes []string) error {
+	entry = strings.ToLower(entry)
+	if entry == "*" {
+		return originError(entry, "the match all origin can't be listed here")
+	}
+	if entry == nullOrigin {
+		return originError(entry, "the null origin can't be listed, use AllowNullOrigin")
+	}
+	if strings.Contains(entry, "://") {
+		return l.addOrigin(entry)
+	}
+	authority, schemeRelative := strings.CutPrefix(entry, "//")
+	if !schemeRelative && strings.IndexByte(entry, ':') >= 0 {
+		return originError(entry, "host with a port must be written as //host:port or include a scheme")
+	}
+	if authority == "" || strings.ContainsAny(authority, "?#@") {
+		return originError(entry, "not a host")
+	}
+	if len(schemes) == 0 {
+		return originError(entry, "no scheme to expand the origin with")
+	}
+	for _, scheme := range schemes {
+		if err := l.addOrigin(strings.ToLower(scheme) + "://" + authority); err != nil {
+			var oerr *OriginError
+			if errors.As(err, &oerr) {
+				oerr.Origin = entry
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *originList) addOrigin(origin string) error {
+	scheme, rest, _ := strings.Cut(origin, "://")
+	switch {
+	case scheme == "" || rest == "":
+		return originError(origin, "missing scheme or host")
+	case strings.ContainsAny(rest, "?#@"):
+		return originError(origin, "an origin has no path, query, fragment or user info")
+	case strings.IndexByte(rest, '/') >= 0:
+		// The only slash allowed is the one of an IP prefix
+		p, err := parsePrefixPattern(origin)
+		switch {
+		case err == errNotPrefix:
+			return originError(origin, "an origin has no path, query, fragment or user info")
+		case err != nil:
+			return originError(origin, err.Error())
+		}
+		p.raw = origin
+		l.pOrigins = append(l.pOrigins, p)
+		return nil
+	}
+	if reason, known := checkSchemeOrigin(scheme, rest); known {
+		if reason != "" {
+			return originError(origin, reason)
+		}
+		if rest == "*" {
+			l.wOrigins = append(l.wOrigins, wildcard{prefix: scheme + "://"})
+		} else {
+			l.addExact(origin)
+		}
+		return nil
+	}
+	_, port, hasPort := splitHostPort(rest)
+	if hasPort && port != "*" && strings.IndexByte(port, '*') >= 0 {
+		return originError(origin, "a port can only be a wildcard or a range of ports")
+	}
+	if hasPort && (port == "*" || strings.IndexByte(port, '-') >= 0) {
+		p, ok := parseOriginPattern(origin)
+		if !ok {
+			return originError(origin, "invalid port wildcard or port range")
+		}
+		p.raw = origin
+		l.pOrigins = append(l.pOrigins, p)
+		return nil
+	}
+	if prefix, suffix, ok := strings.Cut(origin, "*"); ok {
+		if strings.IndexByte(suffix, '*') >= 0 {
+			return originError(origin, "only one wildcard can be used per origin")
+		}
+		// Split the origin in two: start and end string without the *
+		l.wOrigins = append(l.wOrigins, normalizeWildcard(wildcard{prefix, suffix}))
+		return nil
+	}
+	normalized, ok := normalizeOrigin(origin)
+	if !ok {
+		return originError(origin, "malformed origin")
+	}
+	l.addExact(normalized)
+	return nil
+}
+
+func (l *originList) addExact(origin string) {
+	if l.origins == nil {
+		l.origins = map[string]struct{}{}
+	}
+	l.origins[origin] = struct{}{}
+}
+
+// contains reports whether origin matches one of the entries of the list.
+func (l *originList) contains(origin string) bool {
+	origin = strings.ToLower(origin)
+	if _, found := l.origins[origin]; found {
+		return true
+	}
+	// Only normalize origins which didn't match as is so the most common
+	// case stays allocation free.
+	if normalized, ok := normalizeOrigin(origin); ok && normalized != origin {
+		if _, found := l.origins[normalized]; found {
+			return true
+		}
+		origin = normalized
+	}
+	for _, w := range l.wOrigins {
+		if w.match(origin) {
+			return true
+		}
+	}
+	if len(l.pOrigins) > 0 {
+		if scheme, host, port, ok := parseOrigin(origin); ok {
+			for _, p := range l.pOrigins {
+				if p.match(scheme, host, port) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// exact returns the plain origins of the list, sorted.
+func (l *originList) exact() []string {
+	origins := make([]string, 0, len(l.origins))
+	for origin := range l.origins {
+		origins = append(origins, origin)
+	}
+	slices.Sort(origins)
+	return origins
+}
+
+// patterns returns the entries of the list containing wildcards, port
+// wildcards, port ranges or IP prefixes.
+func (l *originList) patterns() []string {
+	var patterns []string
+	for _, w := range l.wOrigins {
+		patterns = append(patterns, w.prefix+"*"+w.suffix)
+	}
+	for _, p := range l.pOrigins {
+		patterns = append(patterns, p.raw)
+	}
+	return patterns
+}
+
+// empty reports whether the list contains no entry.
+func (l *originList) empty() bool {
+	return len(l.origins) == 0 && len(l.wOrigins) == 0 && len(l.pOrigins) == 0
+}
+
+// An OriginError describes an invalid entry in a list of origins.
+type OriginError struct {
+	// Origin is the invalid entry.
+	Origin string
+	// Reason describes why the entry is invalid.
+	Reason string
+}
+
+func originError(origin, reason string) error {
+	return &OriginError{Origin: origin, Reason: reason}
+}
+
+func (e *OriginError) Error() string {
+	return "cors: invalid origin " + strconv.Quote(e.Origin) + ": " + e.Reason
+}
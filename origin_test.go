@@ -0,0 +1,258 @@
+package cors
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseOrigin(t *testing.T) {
+	cases := []struct {
+		origin string
+		scheme string
+		host   string
+		port   int
+		ok     bool
+	}{
+		{"http://localhost", "http", "localhost", 80, true},
+		{"https://example.com", "https", "example.com", 443, true},
+		{"http://localhost:3000", "http", "localhost", 3000, true},
+		{"http://[::1]:8080", "http", "[::1]", 8080, true},
+		{"http://[::1]", "http", "[::1]", 80, true},
+		{"capacitor://localhost", "capacitor", "localhost", 0, true},
+		{"http://localhost:", "", "", 0, false},
+		{"http://localhost:evil", "", "", 0, false},
+		{"http://localhost:0", "", "", 0, false},
+		{"http://localhost:65536", "", "", 0, false},
+		{"http://localhost:+80", "", "", 0, false},
+		{"http://localhost:80/", "", "", 0, false},
+		{"http://[::1", "", "", 0, false},
+		{"http://[::1]x", "", "", 0, false},
+		{"localhost:80", "", "", 0, false},
+		{"null", "", "", 0, false},
+	}
+	for _, tc := range cases {
+		scheme, host, port, ok := parseOrigin(tc.origin)
+		if ok != tc.ok || scheme != tc.scheme || host != tc.host || port != tc.port {
+			t.Errorf("parseOrigin(%q) = %q, %q, %d, %t, want %q, %q, %d, %t",
+				tc.origin, scheme, host, port, ok, tc.scheme, tc.host, tc.port, tc.ok)
+		}
+	}
+}
+
+func TestNormalizeOrigin(t *testing.T) {
+	cases := []struct {
+		origin string
+		want   string
+		ok     bool
+	}{
+		{"https://example.com", "https://example.com", true},
+		{"HTTPS://Example.COM", "https://example.com", true},
+		{"https://example.com:443", "https://example.com", true},
+		{"http://example.com:80", "http://example.com", true},
+		{"http://example.com:443", "http://example.com:443", true},
+		{"http://example.com:08080", "http://example.com:8080", true},
+		{"https://bücher.de", "https://xn--bcher-kva.de", true},
+		{"http://[0:0:0:0:0:0:0:1]:8080", "http://[::1]:8080", true},
+		{"http://[::FFFF:1]", "http://[::ffff:1]", true},
+		{"http://[fe80::1%25eth0]", "", false},
+		{"http://[127.0.0.1]", "", false},
+		{"http://example.com:", "", false},
+		{"null", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := normalizeOrigin(tc.origin)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("normalizeOrigin(%q) = %q, %t, want %q, %t", tc.origin, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestNormalizeWildcard(t *testing.T) {
+	cases := []struct {
+		in   wildcard
+		want wildcard
+	}{
+		{wildcard{"https://", ".example.com"}, wildcard{"https://", ".example.com"}},
+		{wildcard{"https://", ".example.com:443"}, wildcard{"https://", ".example.com"}},
+		{wildcard{"https://", ".example.com:8443"}, wildcard{"https://", ".example.com:8443"}},
+		{wildcard{"https://", ".bücher.de"}, wildcard{"https://", ".xn--bcher-kva.de"}},
+	}
+	for _, tc := range cases {
+		if got := normalizeWildcard(tc.in); got != tc.want {
+			t.Errorf("normalizeWildcard(%v) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestOriginPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		origin  string
+		match   bool
+	}{
+		{"http://localhost:*", "http://localhost:3000", true},
+		{"http://localhost:*", "http://localhost", true},
+		{"http://localhost:*", "http://localhost:evil", false},
+		{"http://localhost:*", "http://localhost:3000.evil.com", false},
+		{"http://localhost:*", "http://localhost.evil.com:3000", false},
+		{"http://localhost:*", "https://localhost:3000", false},
+		{"http://127.0.0.1:3000-3999", "http://127.0.0.1:3000", true},
+		{"http://127.0.0.1:3000-3999", "http://127.0.0.1:3999", true},
+		{"http://127.0.0.1:3000-3999", "http://127.0.0.1:4000", false},
+		{"http://127.0.0.1:3000-3999", "http://127.0.0.1", false},
+		{"http://*.localhost:*", "http://app.localhost:8080", true},
+		{"http://*.localhost:*", "http://localhost:8080", false},
+		{"http://[::1]:*", "http://[::1]:8080", true},
+		{"http://[0::1]:*", "http://[::1]:8080", true},
+		{"http://*.bücher.de:*", "http://www.xn--bcher-kva.de:8080", true},
+	}
+	for _, tc := range cases {
+		p, ok := parseOriginPattern(tc.pattern)
+		if !ok {
+			t.Errorf("parseOriginPattern(%q) failed", tc.pattern)
+			continue
+		}
+		scheme, host, port, ok := parseOrigin(tc.origin)
+		if match := ok && p.match(scheme, host, port); match != tc.match {
+			t.Errorf("%q matching %q = %t, want %t", tc.pattern, tc.origin, match, tc.match)
+		}
+	}
+}
+
+func TestParseOriginPatternRejects(t *testing.T) {
+	for _, pattern := range []string{
+		"http://localhost",
+		"http://localhost:8080",
+		"http://localhost:3999-3000",
+		"http://localhost:0-80",
+		"http://localhost:1-65536",
+		"http://localhost:8*",
+		"http://*.*.localhost:*",
+		"*://localhost:*",
+		"http://localhost:*/",
+	} {
+		if _, ok := parseOriginPattern(pattern); ok {
+			t.Errorf("parseOriginPattern(%q) should fail", pattern)
+		}
+	}
+}
+
+func TestOriginList(t *testing.T) {
+	cases := []struct {
+		entry   string
+		schemes []string
+		origin  string
+		match   bool
+	}{
+		{"example.com", defaultSchemes, "http://example.com", true},
+		{"example.com", defaultSchemes, "https://example.com", true},
+		{"example.com", defaultSchemes, "https://example.com:8443", false},
+		{"example.com", defaultSchemes, "ftp://example.com", false},
+		{"example.com", []string{"https"}, "http://example.com", false},
+		{"*.example.com", defaultSchemes, "https://foo.example.com", true},
+		{"*.example.com", defaultSchemes, "wss://foo.example.com", false},
+		{"//example.com:8443", defaultSchemes, "https://example.com:8443", true},
+		{"//example.com:8443", defaultSchemes, "http://example.com:8443", true},
+		{"//example.com:8443", defaultSchemes, "https://example.com", false},
+		{"//localhost:*", defaultSchemes, "https://localhost:3000", true},
+		{"https://example.com", defaultSchemes, "https://EXAMPLE.com", true},
+		{"10.0.0.0/8", defaultSchemes, "https://10.2.3.4", true},
+		{"http://10.0.0.0/8:*", defaultSchemes, "http://10.2.3.4:8080", true},
+	}
+	for _, tc := range cases {
+		var l originList
+		if err := l.add(tc.entry, tc.schemes); err != nil {
+			t.Errorf("add(%q): unexpected error: %v", tc.entry, err)
+			continue
+		}
+		if match := l.contains(tc.origin); match != tc.match {
+			t.Errorf("%q matching %q = %t, want %t", tc.entry, tc.origin, match, tc.match)
+		}
+	}
+}
+
+func TestOriginListRejects(t *testing.T) {
+	for _, entry := range []string{
+		"example.com:8443",
+		"localhost:*",
+		"example.com/",
+		"//",
+		"https://",
+		"://example.com",
+		"https://example.com/path",
+		"https://user@example.com",
+		"https://*.*.example.com",
+		"http://localhost:80*",
+		"http://localhost:3999-3000",
+		"http://[::1",
+		"http://10.1.2.3/8",
+		"http://example.com/8",
+		"null",
+	} {
+		var l originList
+		err := l.add(entry, defaultSchemes)
+		var oerr *OriginError
+		if !errors.As(err, &oerr) {
+			t.Errorf("add(%q) = %v, want an *OriginError", entry, err)
+			continue
+		}
+		if oerr.Origin != entry {
+			t.Errorf("add(%q) error origin = %q", entry, oerr.Origin)
+		}
+		if !l.empty() {
+			t.Errorf("add(%q) should not add anything", entry)
+		}
+	}
+}
+
+func TestPrefixPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		origin  string
+		match   bool
+	}{
+		{"http://10.0.0.0/8", "http://10.2.3.4", true},
+		{"http://10.0.0.0/8", "http://10.2.3.4:8080", false},
+		{"http://10.0.0.0/8", "http://11.2.3.4", false},
+		{"http://10.0.0.0/8", "https://10.2.3.4", false},
+		{"http://10.0.0.0/8", "http://10.example.com", false},
+		{"http://10.0.0.0/8:*", "http://10.2.3.4:8080", true},
+		{"http://10.0.0.0/8:8080", "http://10.2.3.4:8080", true},
+		{"http://10.0.0.0/8:8000-8999", "http://10.2.3.4:9000", false},
+		{"https://[fd00::]/8:*", "https://[fd12:3456::1]:8443", true},
+		{"https://[fd00::]/8:*", "https://[fe80::1]:8443", false},
+		{"https://[fd00::]/8:*", "https://10.2.3.4:8443", false},
+	}
+	for _, tc := range cases {
+		p, err := parsePrefixPattern(tc.pattern)
+		if err != nil {
+			t.Errorf("parsePrefixPattern(%q): unexpected error: %v", tc.pattern, err)
+			continue
+		}
+		scheme, host, port, ok := parseOrigin(tc.origin)
+		if match := ok && p.match(scheme, host, port); match != tc.match {
+			t.Errorf("%q matching %q = %t, want %t", tc.pattern, tc.origin, match, tc.match)
+		}
+	}
+}
+
+func TestParsePrefixPatternRejects(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		notIP    bool
+		contains string
+	}{
+		{"http://example.com/8", true, ""},
+		{"http://10.0.0.0/33", false, "invalid IP prefix"},
+		{"http://10.1.2.3/8", false, "use 10.0.0.0/8"},
+		{"http://10.0.0.0/8:*x", false, "invalid port"},
+		{"https://[fd00::/8]", true, ""},
+	}
+	for _, tc := range cases {
+		_, err := parsePrefixPattern(tc.pattern)
+		if tc.notIP != (err == errNotPrefix) || err == nil || !strings.Contains(err.Error(), tc.contains) {
+			t.Errorf("parsePrefixPattern(%q) = %v", tc.pattern, err)
+		}
+	}
+}
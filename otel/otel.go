@@ -0,0 +1,99 @@
+// Package cors/otel reports the decisions taken by a github.com/rs/cors
+// handler to OpenTelemetry, as attributes and events of the active span and
+// as a counter.
+package otel
+
+import (
+	"net/http"
+
+	"github.com/rs/cors"
+	otelglobal "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/rs/cors/otel"
+
+// Attribute keys set on the spans and the counter.
+const (
+	// KindKey is "preflight" or "actual".
+	KindKey = attribute.Key("cors.kind")
+	// OriginKey is the origin of the request. It is not set on the counter
+	// to bound its cardinality.
+	OriginKey = attribute.Key("cors.origin")
+	// AllowedKey is whether the request was allowed.
+	AllowedKey = attribute.Key("cors.allowed")
+	// ReasonKey is why the request was rejected, one of the cors.Reason
+	// constants. It is only set on rejected requests.
+	ReasonKey = attribute.Key("cors.reason")
+)
+
+// RejectedEvent is the name of the span event added on rejections.
+const RejectedEvent = "cors.rejected"
+
+// Options configures a Hook.
+type Options struct {
+	// MeterProvider provides the meter of the counter.
+	// Default value is the global MeterProvider.
+	MeterProvider metric.MeterProvider
+}
+
+// Hook is a cors.DecisionHook reporting the decisions to OpenTelemetry:
+//
+//	h, err := otel.NewHook(otel.Options{})
+//	handler := cors.New(cors.Options{DecisionHooks: []cors.DecisionHook{h}})
+//
+// The cors.* attributes are set on the span of the request context, which
+// gets a cors.rejected event when the request is rejected, and the
+// cors.requests counter counts the requests by kind, outcome and reason.
+// Requests without Origin header, which aren't CORS requests, are not
+// reported.
+type Hook struct {
+	requests metric.Int64Counter
+}
+
+var _ cors.DecisionHook = (*Hook)(nil)
+
+// NewHook creates a Hook.
+func NewHook(options Options) (*Hook, error) {
+	mp := options.MeterProvider
+	if mp == nil {
+		mp = otelglobal.GetMeterProvider()
+	}
+	requests, err := mp.Meter(instrumentationName).Int64Counter("cors.requests",
+		metric.WithDescription("CORS requests by kind, outcome and rejection reason."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Hook{requests: requests}, nil
+}
+
+// OnDecision reports d.
+func (h *Hook) OnDecision(r *http.Request, d cors.Decision) {
+	if d.Origin == "" {
+		return
+	}
+	kind := "actual"
+	if d.Preflight {
+		kind = "preflight"
+	}
+	attrs := []attribute.KeyValue{KindKey.String(kind), AllowedKey.Bool(d.Allowed)}
+	if !d.Allowed {
+		attrs = append(attrs, ReasonKey.String(d.Reason))
+	}
+	ctx := r.Context()
+	h.requests.Add(ctx, 1, metric.WithAttributes(attrs...))
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	attrs = append(attrs, OriginKey.String(d.Origin))
+	span.SetAttributes(attrs...)
+	if !d.Allowed {
+		span.AddEvent(RejectedEvent, trace.WithAttributes(attrs...))
+	}
+}
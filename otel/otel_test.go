@@ -0,0 +1,93 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/cors"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHook(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	hook, err := NewHook(Options{MeterProvider: mp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cors.New(cors.Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		DecisionHooks:  []cors.DecisionHook{hook},
+	})
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	send := func(name, method string, headers http.Header) {
+		ctx, span := tp.Tracer("test").Start(context.Background(), name)
+		req, _ := http.NewRequestWithContext(ctx, method, "http://example.com/foo", nil)
+		req.Header = headers
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		span.End()
+	}
+	send("same-origin", http.MethodGet, http.Header{})
+	send("allowed", http.MethodGet, http.Header{"Origin": {"https://foo.com"}})
+	send("rejected", http.MethodOptions, http.Header{"Origin": {"https://bar.com"}, "Access-Control-Request-Method": {http.MethodGet}})
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	if len(spans[0].Attributes) != 0 {
+		t.Errorf("non CORS request got attributes %v", spans[0].Attributes)
+	}
+	wantAllowed := []attribute.KeyValue{KindKey.String("actual"), AllowedKey.Bool(true), OriginKey.String("https://foo.com")}
+	if !equalAttributes(spans[1].Attributes, wantAllowed) || len(spans[1].Events) != 0 {
+		t.Errorf("allowed request got attributes %v and events %v", spans[1].Attributes, spans[1].Events)
+	}
+	wantRejected := []attribute.KeyValue{
+		KindKey.String("preflight"), AllowedKey.Bool(false),
+		ReasonKey.String(cors.ReasonOrigin), OriginKey.String("https://bar.com"),
+	}
+	if !equalAttributes(spans[2].Attributes, wantRejected) {
+		t.Errorf("rejected request got attributes %v", spans[2].Attributes)
+	}
+	if len(spans[2].Events) != 1 || spans[2].Events[0].Name != RejectedEvent {
+		t.Errorf("rejected request got events %v", spans[2].Events)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	if len(rm.ScopeMetrics) != 1 || len(rm.ScopeMetrics[0].Metrics) != 1 {
+		t.Fatalf("got metrics %+v", rm.ScopeMetrics)
+	}
+	sum, ok := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("got %T, want a sum", rm.ScopeMetrics[0].Metrics[0].Data)
+	}
+	counts := map[string]int64{}
+	for _, dp := range sum.DataPoints {
+		kind, _ := dp.Attributes.Value(KindKey)
+		reason, _ := dp.Attributes.Value(ReasonKey)
+		if _, found := dp.Attributes.Value(OriginKey); found {
+			t.Error("the counter should not have an origin attribute")
+		}
+		counts[kind.AsString()+"/"+reason.AsString()] = dp.Value
+	}
+	if len(counts) != 2 || counts["actual/"] != 1 || counts["preflight/origin"] != 1 {
+		t.Errorf("got counts %v", counts)
+	}
+}
+
+func equalAttributes(got, want []attribute.KeyValue) bool {
+	gotSet, wantSet := attribute.NewSet(got...), attribute.NewSet(want...)
+	return gotSet.Equals(&wantSet)
+}
@@ -0,0 +1,112 @@
+package cors
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/cors/internal"
+)
+
+// policy holds the settings applied to the cross-origin requests whose
+// origin is allowed.
+type policy struct {
+	// Normalized list of allowed headers
+	// Note: the Fetch standard guarantees that CORS-unsafe request-header names
+	// (i.e. the values listed in the Access-Control-Request-Headers header)
+	// are unique and sorted;
+	// see https://fetch.spec.whatwg.org/#cors-unsafe-request-header-names.
+	allowedHeaders internal.SortedSet
+	// Normalized list of allowed methods
+	allowedMethods []string
+	// Pre-computed normalized list of exposed headers
+	exposedHeaders []string
+	// Pre-computed maxAge header value
+	maxAge []string
+	// Set to true when allowed headers contains a "*"
+	allowedHeadersAll bool
+	allowCredentials  bool
+}
+
+// setAllowedHeaders normalizes the list of allowed headers, using sensible
+// defaults when empty.
+func (p *policy) setAllowedHeaders(headers []string) {
+	// Note: the Fetch standard guarantees that CORS-unsafe request-header names
+	// (i.e. the values listed in the Access-Control-Request-Headers header)
+	// are lowercase; see https://fetch.spec.whatwg.org/#cors-unsafe-request-header-names.
+	p.allowedHeadersAll = false
+	if len(headers) == 0 {
+		// Use sensible defaults
+		p.allowedHeaders = internal.NewSortedSet("accept", "content-type", "x-requested-with")
+	} else {
+		normalized := convert(headers, strings.ToLower)
+		p.allowedHeaders = internal.NewSortedSet(normalized...)
+		if slices.Contains(headers, "*") {
+			p.allowedHeadersAll = true
+			p.allowedHeaders = internal.SortedSet{}
+		}
+	}
+}
+
+// setAllowedMethods sets the list of allowed methods, defaulting to the
+// spec's "simple" methods when empty.
+func (p *policy) setAllowedMethods(methods []string) {
+	if len(methods) == 0 {
+		// Default is spec's "simple" methods
+		p.allowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
+	} else {
+		p.allowedMethods = methods
+	}
+}
+
+// setExposedHeaders pre-computes the exposed headers header value.
+func (p *policy) setExposedHeaders(headers []string) {
+	p.exposedHeaders = nil
+	if len(headers) > 0 {
+		p.exposedHeaders = []string{strings.Join(convert(headers, http.CanonicalHeaderKey), ", ")}
+	}
+}
+
+// setMaxAge pre-computes the max-age header value.
+func (p *policy) setMaxAge(maxAge int) {
+	p.maxAge = nil
+	if maxAge > 0 {
+		p.maxAge = []string{strconv.Itoa(maxAge)}
+	} else if maxAge < 0 {
+		p.maxAge = []string{"0"}
+	}
+}
+
+// override returns a copy of the policy with the given settings, the empty
+// ones being inherited from p. Credentials are never inherited.
+func (p policy) override(methods, headers, exposedHeaders []string, maxAge int, credentials bool) *policy {
+	if len(methods) > 0 {
+		p.setAllowedMethods(methods)
+	}
+	if len(headers) > 0 {
+		p.setAllowedHeaders(headers)
+	}
+	if len(exposedHeaders) > 0 {
+		p.setExposedHeaders(exposedHeaders)
+	}
+	if maxAge != 0 {
+		p.setMaxAge(maxAge)
+	}
+	p.allowCredentials = credentials
+	return &p
+}
+
+// isMethodAllowed checks if a given method can be used as part of a cross-domain request
+// on the endpoint
+func (p *policy) isMethodAllowed(method string) bool {
+	if len(p.allowedMethods) == 0 {
+		// If no method allowed, always return false, even for preflight request
+		return false
+	}
+	if method == http.MethodOptions {
+		// Always allow preflight requests
+		return true
+	}
+	return slices.Contains(p.allowedMethods, method)
+}
@@ -0,0 +1,129 @@
+package cors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// ManagedOrigins holds the origins managed at runtime by a ReloadablePolicy,
+// on top of the ones of its base options.
+type ManagedOrigins struct {
+	// AllowedOrigins are added to Options.AllowedOrigins.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+	// DeniedOrigins are added to Options.DeniedOrigins.
+	DeniedOrigins []string `json:"deniedOrigins,omitempty"`
+	// Groups maps the names of groups of Options.OriginGroups to the
+	// origins added to them.
+	Groups map[string][]string `json:"groups,omitempty"`
+}
+
+// clone returns a deep copy of m.
+func (m ManagedOrigins) clone() ManagedOrigins {
+	c := ManagedOrigins{
+		AllowedOrigins: slices.Clone(m.AllowedOrigins),
+		DeniedOrigins:  slices.Clone(m.DeniedOrigins),
+	}
+	if m.Groups != nil {
+		c.Groups = make(map[string][]string, len(m.Groups))
+		for name, origins := range m.Groups {
+			c.Groups[name] = slices.Clone(origins)
+		}
+	}
+	return c
+}
+
+// A PolicyStore persists the origins managed by a ReloadablePolicy.
+type PolicyStore interface {
+	// Load returns the stored origins.
+	Load(ctx context.Context) (ManagedOrigins, error)
+	// Save replaces the stored origins.
+	Save(ctx context.Context, origins ManagedOrigins) error
+}
+
+// MemoryPolicyStore is a PolicyStore keeping the origins in memory, for
+// tests or when changes need not survive a restart. It is safe for
+// concurrent use.
+type MemoryPolicyStore struct {
+	mu      sync.Mutex
+	origins ManagedOrigins
+}
+
+// NewMemoryPolicyStore creates a MemoryPolicyStore holding origins.
+func NewMemoryPolicyStore(origins ManagedOrigins) *MemoryPolicyStore {
+	return &MemoryPolicyStore{origins: origins.clone()}
+}
+
+// Load returns the stored origins.
+func (s *MemoryPolicyStore) Load(ctx context.Context) (ManagedOrigins, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.origins.clone(), nil
+}
+
+// Save replaces the stored origins.
+func (s *MemoryPolicyStore) Save(ctx context.Context, origins ManagedOrigins) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.origins = origins.clone()
+	return nil
+}
+
+// FilePolicyStore is a PolicyStore keeping the origins in a JSON file. The
+// file is replaced atomically on save, and a missing file holds no origins.
+// It is safe for concurrent use within a process.
+type FilePolicyStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFilePolicyStore creates a FilePolicyStore using the file at path.
+func NewFilePolicyStore(path string) *FilePolicyStore {
+	return &FilePolicyStore{path: path}
+}
+
+// Load reads the origins from the file.
+func (s *FilePolicyStore) Load(ctx context.Context) (ManagedOrigins, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var origins ManagedOrigins
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return origins, nil
+	}
+	if err != nil {
+		return origins, err
+	}
+	err = json.Unmarshal(data, &origins)
+	return origins, err
+}
+
+// Save writes the origins to a temporary file renamed over the file.
+func (s *FilePolicyStore) Save(ctx context.Context, origins ManagedOrigins) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(origins, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,326 @@
+package cors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrOriginListed is returned when adding an origin already listed.
+	ErrOriginListed = errors.New("cors: origin already listed")
+	// ErrOriginNotListed is returned when removing an origin not listed.
+	ErrOriginNotListed = errors.New("cors: origin not listed")
+	// ErrUnknownGroup is returned when changing the origins of a group
+	// which isn't defined in Options.OriginGroups.
+	ErrUnknownGroup = errors.New("cors: unknown origin group")
+)
+
+// ReloadablePolicyOptions configures a ReloadablePolicy.
+type ReloadablePolicyOptions struct {
+	// Store persists the managed origins.
+	// Default value is an empty MemoryPolicyStore.
+	Store PolicyStore
+	// AuditLog records every change of the policy with the resulting
+	// changes of the effective policy. Changes which can't be recorded are
+	// aborted. No audit log by default.
+	AuditLog AuditLog
+	// Now returns the current time. Default value is time.Now.
+	Now func() time.Time
+}
+
+// ReloadablePolicy is a policy whose allowed origins, denied origins and
+// origin group members can be changed at runtime, on top of base options
+// which can be replaced as well. Each change is validated with the same rules
+// as New, persisted to a PolicyStore and recorded in an AuditLog with the
+// diff of the effective policy before taking effect atomically.
+// Use its Resolve method as Options.PolicyResolver to apply it, and its
+// AdminHandler to manage it over HTTP. It is safe for concurrent use.
+type ReloadablePolicy struct {
+	base     Options
+	store    PolicyStore
+	auditLog AuditLog
+	now      func() time.Time
+
+	current atomic.Pointer[Cors]
+
+	// Serializes changes and guards origins and effective
+	mu      sync.Mutex
+	origins ManagedOrigins
+	// Options of the current policy
+	effective Options
+}
+
+// NewReloadablePolicy creates a ReloadablePolicy extending base with the
+// origins loaded from the store.
+func NewReloadablePolicy(ctx context.Context, base Options, options ReloadablePolicyOptions) (*ReloadablePolicy, error) {
+	p := &ReloadablePolicy{
+		base:     base,
+		store:    options.Store,
+		auditLog: options.AuditLog,
+		now:      options.Now,
+	}
+	// The policy is resolved by the caller's handler
+	p.base.PolicyResolver = nil
+	if p.store == nil {
+		p.store = NewMemoryPolicyStore(ManagedOrigins{})
+	}
+	if p.now == nil {
+		p.now = time.Now
+	}
+	origins, err := p.store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o, err := p.options(p.base, origins)
+	if err != nil {
+		return nil, err
+	}
+	p.apply(o, origins)
+	return p, nil
+}
+
+// Resolve returns the current policy, for use as Options.PolicyResolver.
+func (p *ReloadablePolicy) Resolve(r *http.Request) (*Cors, error) {
+	return p.current.Load(), nil
+}
+
+// Cors returns the current policy.
+func (p *ReloadablePolicy) Cors() *Cors {
+	return p.current.Load()
+}
+
+// Origins returns the managed origins.
+func (p *ReloadablePolicy) Origins() ManagedOrigins {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.origins.clone()
+}
+
+// Reload loads the managed origins from the store again, i.e. after the
+// file of a FilePolicyStore was edited. Invalid origins are rejected and the
+// current policy kept. Changes are recorded with the actor carried by ctx and
+// the given reason.
+func (p *ReloadablePolicy) Reload(ctx context.Context, reason string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	origins, err := p.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	o, err := p.options(p.base, origins)
+	if err != nil {
+		return err
+	}
+	if err := p.record(ctx, AuditEntry{Action: AuditReload, Reason: reason}, o); err != nil {
+		return err
+	}
+	p.apply(o, origins)
+	return nil
+}
+
+// SetOptions replaces the base options, i.e. when a configuration file
+// changed, keeping the managed origins. Groups with managed origins can't be
+// removed. Changes are recorded with the actor carried by ctx and the given
+// reason.
+func (p *ReloadablePolicy) SetOptions(ctx context.Context, base Options, reason string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	base.PolicyResolver = nil
+	o, err := p.options(base, p.origins)
+	if err != nil {
+		return err
+	}
+	if err := p.record(ctx, AuditEntry{Action: AuditUpdate, Reason: reason}, o); err != nil {
+		return err
+	}
+	p.base = base
+	p.apply(o, p.origins)
+	return nil
+}
+
+// Allow adds origin to the allowed origins.
+func (p *ReloadablePolicy) Allow(ctx context.Context, origin, reason string) error {
+	return p.change(ctx, AuditEntry{Action: AuditAdd, List: AuditAllowed, Origin: origin, Reason: reason})
+}
+
+// RemoveAllowed removes origin from the allowed origins.
+func (p *ReloadablePolicy) RemoveAllowed(ctx context.Context, origin, reason string) error {
+	return p.change(ctx, AuditEntry{Action: AuditRemove, List: AuditAllowed, Origin: origin, Reason: reason})
+}
+
+// Deny adds origin to the denied origins.
+func (p *ReloadablePolicy) Deny(ctx context.Context, origin, reason string) error {
+	return p.change(ctx, AuditEntry{Action: AuditAdd, List: AuditDenied, Origin: origin, Reason: reason})
+}
+
+// RemoveDenied removes origin from the denied origins.
+func (p *ReloadablePolicy) RemoveDenied(ctx context.Context, origin, reason string) error {
+	return p.change(ctx, AuditEntry{Action: AuditRemove, List: AuditDenied, Origin: origin, Reason: reason})
+}
+
+// AddToGroup adds origin to the origin group named group, which must be
+// defined in Options.OriginGroups.
+func (p *ReloadablePolicy) AddToGroup(ctx context.Context, group, origin, reason string) error {
+	return p.change(ctx, AuditEntry{Action: AuditAdd, List: AuditGroup, Group: group, Origin: origin, Reason: reason})
+}
+
+// RemoveFromGroup removes origin from the origin group named group.
+func (p *ReloadablePolicy) RemoveFromGroup(ctx context.Context, group, origin, reason string) error {
+	return p.change(ctx, AuditEntry{Action: AuditRemove, List: AuditGroup, Group: group, Origin: origin, Reason: reason})
+}
+
+// change applies the change described by e, then persists, applies and
+// records the resulting origins.
+func (p *ReloadablePolicy) change(ctx context.Context, e AuditEntry) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	origins := p.origins.clone()
+	var list []string
+	switch e.List {
+	case AuditAllowed:
+		list = origins.AllowedOrigins
+	case AuditDenied:
+		list = origins.DeniedOrigins
+	case AuditGroup:
+		if !p.hasGroup(e.Group) {
+			return ErrUnknownGroup
+		}
+		list = origins.Groups[e.Group]
+	}
+
+	e.Origin = strings.ToLower(e.Origin)
+	i := slices.Index(list, e.Origin)
+	switch e.Action {
+	case AuditAdd:
+		if i >= 0 {
+			return ErrOriginListed
+		}
+		// Listed entries follow the rules of AllowedOrigins, where New
+		// handles "*" and "null" specially.
+		if err := (&originList{}).add(e.Origin, p.schemes()); err != nil {
+			return err
+		}
+		list = append(list, e.Origin)
+	case AuditRemove:
+		if i < 0 {
+			return ErrOriginNotListed
+		}
+		list = slices.Delete(list, i, i+1)
+		if len(list) == 0 {
+			list = nil
+		}
+	}
+
+	switch e.List {
+	case AuditAllowed:
+		origins.AllowedOrigins = list
+	case AuditDenied:
+		origins.DeniedOrigins = list
+	case AuditGroup:
+		if origins.Groups == nil {
+			origins.Groups = map[string][]string{}
+		}
+		origins.Groups[e.Group] = list
+		if len(list) == 0 {
+			delete(origins.Groups, e.Group)
+		}
+		if len(origins.Groups) == 0 {
+			origins.Groups = nil
+		}
+	}
+
+	o, err := p.options(p.base, origins)
+	if err != nil {
+		return err
+	}
+	if err := p.store.Save(ctx, origins); err != nil {
+		return err
+	}
+	if err := p.record(ctx, e, o); err != nil {
+		// Restore the stored origins of the current policy
+		return errors.Join(err, p.store.Save(ctx, p.origins))
+	}
+	p.apply(o, origins)
+	return nil
+}
+
+// apply makes o the current policy. It must be called with mu held.
+func (p *ReloadablePolicy) apply(o Options, origins ManagedOrigins) {
+	p.origins = origins
+	p.effective = o
+	p.current.Store(New(o))
+}
+
+// record records e with the diff from the current policy to o. Changes are
+// recorded even when the diff is empty, as it can't describe all of them
+// (i.e.: the replacement of an AllowOriginFunc). It must be called with mu
+// held.
+func (p *ReloadablePolicy) record(ctx context.Context, e AuditEntry, o Options) error {
+	if p.auditLog == nil {
+		return nil
+	}
+	diff := DiffOptions(p.effective, o)
+	e.Time = p.now()
+	e.Actor = ActorFromContext(ctx)
+	e.Diff = &diff
+	return p.auditLog.Record(ctx, e)
+}
+
+// options returns the options of the policy with the given base and managed
+// origins, validated with the same rules as New.
+func (p *ReloadablePolicy) options(base Options, origins ManagedOrigins) (Options, error) {
+	o := base
+	o.AllowedOrigins = slices.Concat(base.AllowedOrigins, origins.AllowedOrigins)
+	o.DeniedOrigins = slices.Concat(base.DeniedOrigins, origins.DeniedOrigins)
+	o.OriginGroups = slices.Clone(base.OriginGroups)
+	for i, g := range o.OriginGroups {
+		o.OriginGroups[i].Origins = slices.Concat(g.Origins, origins.Groups[g.Name])
+	}
+	for name := range origins.Groups {
+		if !slices.ContainsFunc(base.OriginGroups, func(g OriginGroup) bool { return g.Name == name }) {
+			return o, fmt.Errorf("%w %q", ErrUnknownGroup, name)
+		}
+	}
+	if len(o.AllowedOrigins) == 0 && !o.hasOriginOptions() && o.AllowOriginFunc == nil &&
+		o.AllowOriginRequestFunc == nil && o.AllowOriginVaryRequestFunc == nil && o.OriginStore == nil {
+		// Removing the last managed origin must not allow all origins
+		o.noOrigins = true
+	}
+	return o, o.Validate()
+}
+
+func (p *ReloadablePolicy) hasGroup(name string) bool {
+	return slices.ContainsFunc(p.base.OriginGroups, func(g OriginGroup) bool {
+		return g.Name == name
+	})
+}
+
+func (p *ReloadablePolicy) schemes() []string {
+	if len(p.base.DefaultSchemes) > 0 {
+		return p.base.DefaultSchemes
+	}
+	return defaultSchemes
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor recorded in the audit
+// log for the changes made with it. Authentication middlewares in front of
+// the AdminHandler of a ReloadablePolicy use it to identify the caller.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by ctx, if any.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
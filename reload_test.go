@@ -0,0 +1,266 @@
+package cors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func reloadableAllowed(t *testing.T, h http.Handler, origin string) bool {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Add("Origin", origin)
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res.Header().Get("Access-Control-Allow-Origin") == origin
+}
+
+func TestReloadablePolicy(t *testing.T) {
+	var entries []AuditEntry
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p, err := NewReloadablePolicy(context.Background(), Options{
+		AllowedOrigins: []string{"https://base.com"},
+		OriginGroups:   []OriginGroup{{Name: "partners", Origins: []string{"https://partner.com"}, AllowCredentials: true}},
+	}, ReloadablePolicyOptions{
+		AuditLog: AuditLogFunc(func(ctx context.Context, e AuditEntry) error {
+			entries = append(entries, e)
+			return nil
+		}),
+		Now: func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(Options{PolicyResolver: p.Resolve}).Handler(testHandler)
+	ctx := WithActor(context.Background(), "alice")
+
+	if reloadableAllowed(t, h, "https://foo.com") {
+		t.Error("origin allowed before being added")
+	}
+	if err := p.Allow(ctx, "https://FOO.com", "vendor demo"); err != nil {
+		t.Fatal(err)
+	}
+	if !reloadableAllowed(t, h, "https://foo.com") || !reloadableAllowed(t, h, "https://base.com") {
+		t.Error("added origin or base origin not allowed")
+	}
+	if err := p.Deny(ctx, "https://base.com", "compromised"); err != nil {
+		t.Fatal(err)
+	}
+	if reloadableAllowed(t, h, "https://base.com") {
+		t.Error("denied origin allowed")
+	}
+	if err := p.AddToGroup(ctx, "partners", "https://acme.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://acme.com")
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	if res.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Error("group member should get the settings of the group")
+	}
+
+	want := ManagedOrigins{
+		AllowedOrigins: []string{"https://foo.com"},
+		DeniedOrigins:  []string{"https://base.com"},
+		Groups:         map[string][]string{"partners": {"https://acme.com"}},
+	}
+	if got := p.Origins(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got origins %+v, want %+v", got, want)
+	}
+
+	for _, err := range []error{
+		p.RemoveAllowed(ctx, "https://foo.com", ""),
+		p.RemoveDenied(ctx, "https://base.com", ""),
+		p.RemoveFromGroup(ctx, "partners", "https://acme.com", ""),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(p.Origins(), ManagedOrigins{}) {
+		t.Errorf("got origins %+v after removals", p.Origins())
+	}
+
+	if len(entries) != 6 {
+		t.Fatalf("got %d audit entries, want 6", len(entries))
+	}
+	wantEntry := AuditEntry{
+		Time: now, Actor: "alice", Reason: "vendor demo", Action: AuditAdd, List: AuditAllowed, Origin: "https://foo.com",
+		Diff: &PolicyDiff{SettingsDiff: SettingsDiff{AddedOrigins: []string{"https://foo.com"}}},
+	}
+	if !reflect.DeepEqual(entries[0], wantEntry) {
+		t.Errorf("got audit entry %+v, want %+v", entries[0], wantEntry)
+	}
+	wantEntry = AuditEntry{
+		Time: now, Actor: "alice", Action: AuditRemove, List: AuditGroup, Group: "partners", Origin: "https://acme.com",
+		Diff: &PolicyDiff{Groups: []GroupDiff{{Name: "partners", SettingsDiff: SettingsDiff{RemovedOrigins: []string{"https://acme.com"}}}}},
+	}
+	if !reflect.DeepEqual(entries[5], wantEntry) {
+		t.Errorf("got audit entry %+v, want %+v", entries[5], wantEntry)
+	}
+}
+
+func TestReloadablePolicyRejects(t *testing.T) {
+	var entries int
+	p, err := NewReloadablePolicy(context.Background(), Options{
+		OriginGroups: []OriginGroup{{Name: "partners"}},
+	}, ReloadablePolicyOptions{
+		AuditLog: AuditLogFunc(func(ctx context.Context, e AuditEntry) error {
+			entries++
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := p.Allow(ctx, "https://foo.com", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var originErr *OriginError
+	cases := []struct {
+		name  string
+		err   error
+		check func(error) bool
+	}{
+		{"Invalid", p.Allow(ctx, "https://foo.com/path", ""), func(err error) bool { return errors.As(err, &originErr) }},
+		{"MatchAll", p.Allow(ctx, "*", ""), func(err error) bool { return errors.As(err, &originErr) }},
+		{"Null", p.Deny(ctx, "null", ""), func(err error) bool { return errors.As(err, &originErr) }},
+		{"Duplicate", p.Allow(ctx, "https://FOO.com", ""), func(err error) bool { return errors.Is(err, ErrOriginListed) }},
+		{"NotListed", p.RemoveDenied(ctx, "https://foo.com", ""), func(err error) bool { return errors.Is(err, ErrOriginNotListed) }},
+		{"UnknownGroup", p.AddToGroup(ctx, "vendors", "https://foo.com", ""), func(err error) bool { return errors.Is(err, ErrUnknownGroup) }},
+	}
+	for _, tc := range cases {
+		if !tc.check(tc.err) {
+			t.Errorf("%s: unexpected error %v", tc.name, tc.err)
+		}
+	}
+	if entries != 1 {
+		t.Errorf("got %d audit entries, want 1", entries)
+	}
+
+	// Removing the last origin must not allow all origins
+	if err := p.RemoveAllowed(ctx, "https://foo.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	h := New(Options{PolicyResolver: p.Resolve}).Handler(testHandler)
+	if reloadableAllowed(t, h, "https://foo.com") {
+		t.Error("empty policy allows all origins")
+	}
+}
+
+func TestFilePolicyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "origins.json")
+	store := NewFilePolicyStore(path)
+	ctx := context.Background()
+
+	origins, err := store.Load(ctx)
+	if err != nil || !reflect.DeepEqual(origins, ManagedOrigins{}) {
+		t.Fatalf("Load of a missing file = %+v, %v", origins, err)
+	}
+
+	p, err := NewReloadablePolicy(ctx, Options{}, ReloadablePolicyOptions{Store: store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Allow(ctx, "https://foo.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved ManagedOrigins
+	if err := json.Unmarshal(data, &saved); err != nil || !reflect.DeepEqual(saved.AllowedOrigins, []string{"https://foo.com"}) {
+		t.Errorf("saved %s, %v", data, err)
+	}
+
+	// Reload picks up external edits, and rejects invalid ones
+	os.WriteFile(path, []byte(`{"allowedOrigins": ["https://bar.com"]}`), 0o600)
+	if err := p.Reload(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	h := New(Options{PolicyResolver: p.Resolve}).Handler(testHandler)
+	if !reloadableAllowed(t, h, "https://bar.com") || reloadableAllowed(t, h, "https://foo.com") {
+		t.Error("reloaded origins not applied")
+	}
+	os.WriteFile(path, []byte(`{"allowedOrigins": ["https://bar.com/path"]}`), 0o600)
+	if err := p.Reload(ctx, ""); err == nil {
+		t.Error("invalid origins should be rejected")
+	}
+	if !reloadableAllowed(t, h, "https://bar.com") {
+		t.Error("policy should be kept after an invalid reload")
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	if len(matches) > 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+}
+
+func TestJSONAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewJSONAuditLog(&buf)
+	err := l.Record(context.Background(), AuditEntry{
+		Time:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Actor:  "alice",
+		Action: AuditAdd,
+		List:   AuditAllowed,
+		Origin: "https://foo.com",
+	})
+	want := `{"time":"2024-01-01T00:00:00Z","actor":"alice","action":"add","list":"allowed","origin":"https://foo.com"}`
+	if got := strings.TrimSpace(buf.String()); got != want || err != nil {
+		t.Errorf("got %s, %v, want %s", got, err, want)
+	}
+
+	// Write errors are reported
+	if err := NewJSONAuditLog(failingWriter{}).Record(context.Background(), AuditEntry{}); err == nil {
+		t.Error("write errors should be returned")
+	}
+}
+
+func TestReloadablePolicyAuditFailure(t *testing.T) {
+	store := NewMemoryPolicyStore(ManagedOrigins{AllowedOrigins: []string{"https://foo.com"}})
+	p, err := NewReloadablePolicy(context.Background(), Options{}, ReloadablePolicyOptions{
+		Store:    store,
+		AuditLog: NewJSONAuditLog(failingWriter{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := p.Allow(ctx, "https://bar.com", ""); err == nil {
+		t.Error("Allow should fail when the change can't be recorded")
+	}
+	if err := p.SetOptions(ctx, Options{AllowCredentials: true}, ""); err == nil {
+		t.Error("SetOptions should fail when the change can't be recorded")
+	}
+	if err := p.Reload(ctx, ""); err == nil {
+		t.Error("Reload should fail when the change can't be recorded")
+	}
+	// Nothing changed
+	h := New(Options{PolicyResolver: p.Resolve}).Handler(testHandler)
+	if reloadableAllowed(t, h, "https://bar.com") || p.Cors().allowCredentials {
+		t.Error("unrecorded change applied")
+	}
+	if origins, _ := store.Load(ctx); !reflect.DeepEqual(origins.AllowedOrigins, []string{"https://foo.com"}) {
+		t.Errorf("unrecorded change persisted: %v", origins.AllowedOrigins)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
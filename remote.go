@@ -0,0 +1,227 @@
+package cors
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RemoteOriginsOptions configures a RemoteOrigins provider.
+type RemoteOriginsOptions struct {
+	// URL of the JSON document listing the allowed origins, either as an
+	// array of strings or as an object with an "origins" array of strings.
+	// Entries use the same syntax as `Options.AllowedOrigins` (except `*`).
+	URL string
+	// Client is the HTTP client used to fetch the document.
+	// Default value is http.DefaultClient.
+	Client *http.Client
+	// Interval between two fetches of the document.
+	// Default value is 5 minutes.
+	Interval time.Duration
+	// RetryDelay is the delay before retrying a failed fetch. It doubles
+	// after each consecutive failure, up to Interval.
+	// Default value is 10 seconds.
+	RetryDelay time.Duration
+	// DefaultSchemes lists the schemes used to expand entries without one.
+	// Default value is http and https.
+	DefaultSchemes []string
+	// Logger reports fetch failures. No logging by default.
+	Logger Logger
+}
+
+// RemoteOrigins is an OriginStore serving a list of allowed origins fetched
+// from an HTTP endpoint and kept up to date by Run. A new list is validated
+// as a whole and atomically replaces the active one, so a faulty document
+// never takes effect. It is safe for concurrent use.
+type RemoteOrigins struct {
+	url        string
+	client     *http.Client
+	interval   time.Duration
+	retryDelay time.Duration
+	schemes    []string
+	log        Logger
+
+	origins     atomic.Pointer[originList]
+	lastSuccess atomic.Pointer[time.Time]
+
+	// Serializes fetches and guards etag
+	mu   sync.Mutex
+	etag string
+}
+
+// ErrOriginsNotLoaded is returned by RemoteOrigins lookups until the list
+// of origins has been fetched successfully once.
+var ErrOriginsNotLoaded = errors.New("cors: remote origins not loaded yet")
+
+// maxRemoteOriginsSize bounds the size of a remote origins document.
+const maxRemoteOriginsSize = 1 << 20
+
+// NewRemoteOrigins creates a RemoteOrigins provider. No fetch happens until
+// Refresh or Run is called.
+func NewRemoteOrigins(options RemoteOriginsOptions) *RemoteOrigins {
+	ro := &RemoteOrigins{
+		url:        options.URL,
+		client:     options.Client,
+		interval:   options.Interval,
+		retryDelay: options.RetryDelay,
+		schemes:    options.DefaultSchemes,
+		log:        options.Logger,
+	}
+	if ro.client == nil {
+		ro.client = http.DefaultClient
+	}
+	if ro.interval <= 0 {
+		ro.interval = 5 * time.Minute
+	}
+	if ro.retryDelay <= 0 {
+		ro.retryDelay = 10 * time.Second
+	}
+	if len(ro.schemes) == 0 {
+		ro.schemes = defaultSchemes
+	}
+	return ro
+}
+
+// Lookup reports whether origin is in the active list. It returns
+// ErrOriginsNotLoaded until the first successful fetch.
+func (ro *RemoteOrigins) Lookup(ctx context.Context, origin string) (bool, error) {
+	l := ro.origins.Load()
+	if l == nil {
+		return false, ErrOriginsNotLoaded
+	}
+	return l.contains(origin), nil
+}
+
+// LastSuccess returns the time of the last successful fetch, including the
+// ones finding the document unmodified, or the zero time if none succeeded.
+func (ro *RemoteOrigins) LastSuccess() time.Time {
+	if t := ro.lastSuccess.Load(); t != nil {
+		return *t
+	}
+	return time.Time{}
+}
+
+// Refresh fetches the document once, sending the ETag of the active list in
+// If-None-Match, and replaces the active list if it changed. On error, the
+// active list is kept.
+func (ro *RemoteOrigins) Refresh(ctx context.Context) error {
+	ro.mu.Lock()
+	defer ro.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ro.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if ro.etag != "" {
+		req.Header.Set("If-None-Match", ro.etag)
+	}
+	res, err := ro.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		if ro.origins.Load() == nil {
+			return errors.New("cors: remote origins: not modified before any list was loaded")
+		}
+	case http.StatusOK:
+		body, err := io.ReadAll(io.LimitReader(res.Body, maxRemoteOriginsSize+1))
+		if err != nil {
+			return err
+		}
+		if len(body) > maxRemoteOriginsSize {
+			return errors.New("cors: remote origins: document too large")
+		}
+		l, err := parseRemoteOrigins(body, ro.schemes)
+		if err != nil {
+			return err
+		}
+		ro.origins.Store(l)
+		ro.etag = res.Header.Get("ETag")
+	default:
+		return fmt.Errorf("cors: remote origins: unexpected status %s", res.Status)
+	}
+	now := time.Now()
+	ro.lastSuccess.Store(&now)
+	return nil
+}
+
+// parseRemoteOrigins validates and compiles a remote origins document.
+func parseRemoteOrigins(body []byte, schemes []string) (*originList, error) {
+	var entries []string
+	if err := json.Unmarshal(body, &entries); err != nil || entries == nil {
+		var doc struct {
+			Origins *[]string `json:"origins"`
+		}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return nil, fmt.Errorf("cors: remote origins: %w", err)
+		}
+		// An object without origins, i.e. an error document, must not
+		// empty the list
+		if doc.Origins == nil {
+			return nil, errors.New("cors: remote origins: missing origins array")
+		}
+		entries = *doc.Origins
+	}
+	l := &originList{}
+	var errs []error
+	for _, entry := range entries {
+		if err := l.add(entry, schemes); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return l, nil
+}
+
+// Run refreshes the list until ctx is done, every Interval after a success
+// and with an exponential backoff after failures. The first fetch happens
+// immediately.
+func (ro *RemoteOrigins) Run(ctx context.Context) {
+	failures := 0
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if err := ro.Refresh(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			failures++
+			if ro.log != nil {
+				ro.log.Printf("Remote origins refresh failed (%d in a row): %v", failures, err)
+			}
+		} else {
+			failures = 0
+		}
+		timer.Reset(ro.nextDelay(failures))
+	}
+}
+
+// nextDelay returns the delay before the next fetch after the given number
+// of consecutive failures.
+func (ro *RemoteOrigins) nextDelay(failures int) time.Duration {
+	if failures == 0 {
+		return ro.interval
+	}
+	d := ro.retryDelay
+	for i := 1; i < failures && d < ro.interval; i++ {
+		d *= 2
+	}
+	return min(d, ro.interval)
+}
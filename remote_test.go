@@ -0,0 +1,254 @@
+package cors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type remoteOriginsServer struct {
+	mu       sync.Mutex
+	body     string
+	etag     string
+	status   int
+	requests int
+	matched  int
+}
+
+func (s *remoteOriginsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+	if s.etag != "" && r.Header.Get("If-None-Match") == s.etag {
+		s.matched++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	w.Write([]byte(s.body))
+}
+
+func (s *remoteOriginsServer) set(body, etag string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body, s.etag, s.status = body, etag, status
+}
+
+func (s *remoteOriginsServer) counts() (requests, matched int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.matched
+}
+
+func TestRemoteOriginsRefresh(t *testing.T) {
+	srv := &remoteOriginsServer{body: `{"origins": ["https://foo.com", "*.bar.com"]}`, etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	ro := NewRemoteOrigins(RemoteOriginsOptions{URL: ts.URL})
+	ctx := context.Background()
+
+	if _, err := ro.Lookup(ctx, "https://foo.com"); !errors.Is(err, ErrOriginsNotLoaded) {
+		t.Errorf("Lookup before the first fetch: got error %v", err)
+	}
+	if !ro.LastSuccess().IsZero() {
+		t.Error("LastSuccess should be zero before the first fetch")
+	}
+
+	if err := ro.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertLookups := func(want map[string]bool) {
+		t.Helper()
+		for origin, allowed := range want {
+			if got, err := ro.Lookup(ctx, origin); got != allowed || err != nil {
+				t.Errorf("Lookup(%q) = %v, %v, want %v", origin, got, err, allowed)
+			}
+		}
+	}
+	assertLookups(map[string]bool{
+		"https://foo.com":     true,
+		"https://www.bar.com": true,
+		"http://www.bar.com":  true,
+		"https://baz.com":     false,
+	})
+	first := ro.LastSuccess()
+	if first.IsZero() {
+		t.Error("LastSuccess not set")
+	}
+
+	// Unmodified document
+	if err := ro.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, matched := srv.counts(); matched != 1 {
+		t.Errorf("got %d conditional hits, want 1", matched)
+	}
+	if ro.LastSuccess().Before(first) {
+		t.Error("LastSuccess not updated on a not modified response")
+	}
+
+	// New document, as a plain array
+	srv.set(`["https://baz.com"]`, `"v2"`, 0)
+	if err := ro.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertLookups(map[string]bool{
+		"https://foo.com": false,
+		"https://baz.com": true,
+	})
+}
+
+func TestRemoteOriginsInvalid(t *testing.T) {
+	srv := &remoteOriginsServer{body: `["https://foo.com"]`, etag: `"v1"`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	ro := NewRemoteOrigins(RemoteOriginsOptions{URL: ts.URL})
+	ctx := context.Background()
+	if err := ro.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	lastSuccess := ro.LastSuccess()
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		err    string
+	}{
+		{"InvalidOrigin", `["https://baz.com", "https://baz.com/path"]`, 0, "invalid origin"},
+		{"MatchAll", `["*"]`, 0, "invalid origin"},
+		{"InvalidJSON", `{"origins": "https://baz.com"}`, 0, "remote origins"},
+		{"MissingOrigins", `{"error": "backend unavailable"}`, 0, "missing origins"},
+		{"NullOrigins", `{"origins": null}`, 0, "missing origins"},
+		{"Null", `null`, 0, "missing origins"},
+		{"ServerError", "", http.StatusInternalServerError, "unexpected status"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv.set(tc.body, `"v2"`, tc.status)
+			err := ro.Refresh(ctx)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("got error %v, want %q", err, tc.err)
+			}
+			// The active list is kept
+			if allowed, _ := ro.Lookup(ctx, "https://foo.com"); !allowed {
+				t.Error("active list replaced by an invalid one")
+			}
+			if allowed, _ := ro.Lookup(ctx, "https://baz.com"); allowed {
+				t.Error("invalid list partially applied")
+			}
+			if !ro.LastSuccess().Equal(lastSuccess) {
+				t.Error("LastSuccess updated on failure")
+			}
+		})
+	}
+}
+
+func TestRemoteOriginsRun(t *testing.T) {
+	srv := &remoteOriginsServer{status: http.StatusServiceUnavailable}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	logger := &syncLogger{}
+	ro := NewRemoteOrigins(RemoteOriginsOptions{
+		URL:        ts.URL,
+		Interval:   time.Hour,
+		RetryDelay: time.Millisecond,
+		Logger:     logger,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ro.Run(ctx)
+		close(done)
+	}()
+
+	// Failures are retried until the document is available
+	deadline := time.Now().Add(time.Second)
+	for {
+		if requests, _ := srv.counts(); requests >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("failed fetch not retried")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	srv.set(`["https://foo.com"]`, `"v1"`, 0)
+	for ro.LastSuccess().IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("list never loaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if allowed, _ := ro.Lookup(ctx, "https://foo.com"); !allowed {
+		t.Error("loaded list not active")
+	}
+	if !strings.Contains(logger.String(), "Remote origins refresh failed") {
+		t.Error("failure not logged")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+}
+
+func TestRemoteOriginsBackoff(t *testing.T) {
+	ro := NewRemoteOrigins(RemoteOriginsOptions{Interval: time.Minute, RetryDelay: 10 * time.Second})
+	want := []time.Duration{time.Minute, 10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for failures, d := range want {
+		if got := ro.nextDelay(failures); got != d {
+			t.Errorf("nextDelay(%d) = %v, want %v", failures, got, d)
+		}
+	}
+}
+
+func TestRemoteOriginsOption(t *testing.T) {
+	srv := &remoteOriginsServer{body: `["https://foo.com"]`}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	ro := NewRemoteOrigins(RemoteOriginsOptions{URL: ts.URL})
+	if err := ro.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	h := New(Options{OriginStore: ro}).Handler(testHandler)
+	for origin, allowed := range map[string]bool{"https://foo.com": true, "https://bar.com": false} {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+		req.Header.Add("Origin", origin)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if got := res.Header().Get("Access-Control-Allow-Origin") == origin; got != allowed {
+			t.Errorf("%s: got allowed %v, want %v", origin, got, allowed)
+		}
+	}
+}
+
+type syncLogger struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *syncLogger) Printf(format string, v ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf.WriteString(strings.TrimSpace(format))
+	l.buf.WriteByte('\n')
+}
+
+func (l *syncLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
@@ -0,0 +1,106 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/rs/cors/internal"
+)
+
+// resolve returns the Cors handler whose policy applies to the request: the
+// one returned by the policy resolver if any, one rejecting all origins when
+// the resolver fails, c otherwise.
+func (c *Cors) resolve(r *http.Request) *Cors {
+	if c.policyResolver == nil {
+		return c
+	}
+	rc, err := c.policyResolver(r)
+	if err != nil {
+		c.rlogf(r, "  Policy resolution failed, rejecting origin: %v", err)
+		return c.unresolved
+	}
+	if rc == nil {
+		return c
+	}
+	return rc
+}
+
+// PolicyCache is a bounded cache of compiled per-tenant policies, evicting
+// the least recently used ones. It is safe for concurrent use.
+type PolicyCache struct {
+	load func(ctx context.Context, key string) (Options, error)
+
+	mu       sync.Mutex
+	policies *internal.LRU[string, *Cors]
+}
+
+// NewPolicyCache creates a PolicyCache holding up to size policies, loading
+// the options of missing ones with load.
+func NewPolicyCache(size int, load func(ctx context.Context, key string) (Options, error)) *PolicyCache {
+	return &PolicyCache{
+		load:     load,
+		policies: internal.NewLRU[string, *Cors](size),
+	}
+}
+
+// Get returns the compiled policy for key, loading it if it isn't cached.
+// Load errors are not cached.
+func (pc *PolicyCache) Get(ctx context.Context, key string) (*Cors, error) {
+	pc.mu.Lock()
+	c, found := pc.policies.Get(key)
+	pc.mu.Unlock()
+	if found {
+		return c, nil
+	}
+
+	options, err := pc.load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	c = New(options)
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if cached, found := pc.policies.Get(key); found {
+		// Loaded concurrently, keep the cached policy
+		return cached, nil
+	}
+	pc.policies.Add(key, c)
+	return c, nil
+}
+
+// Invalidate removes the policy for key from the cache, so it is loaded
+// again on next use. Call it when the configuration of a tenant changes.
+func (pc *PolicyCache) Invalidate(key string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.policies.Remove(key)
+}
+
+// Purge removes all the policies from the cache.
+func (pc *PolicyCache) Purge() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.policies.Purge()
+}
+
+// Len returns the number of cached policies.
+func (pc *PolicyCache) Len() int {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.policies.Len()
+}
+
+// Resolver returns a function suitable for Options.PolicyResolver, resolving
+// the policy of the tenant identified by key (i.e.: from the request context
+// set by an upstream middleware).
+func (pc *PolicyCache) Resolver(key func(r *http.Request) (string, error)) func(r *http.Request) (*Cors, error) {
+	return func(r *http.Request) (*Cors, error) {
+		k, err := key(r)
+		if err != nil {
+			return nil, err
+		}
+		return pc.Get(r.Context(), k)
+	}
+}
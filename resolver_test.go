@@ -0,0 +1,160 @@
+package cors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type tenantKey struct{}
+
+func tenantFromRequest(r *http.Request) (string, error) {
+	tenant, ok := r.Context().Value(tenantKey{}).(string)
+	if !ok {
+		return "", errors.New("no tenant")
+	}
+	return tenant, nil
+}
+
+func TestPolicyResolver(t *testing.T) {
+	tenants := map[string]Options{
+		"acme": {
+			AllowedOrigins:   []string{"https://acme.com"},
+			AllowCredentials: true,
+		},
+		"globex": {
+			AllowedOrigins: []string{"https://globex.com"},
+			AllowedMethods: []string{http.MethodGet, http.MethodPut},
+		},
+	}
+	cache := NewPolicyCache(10, func(ctx context.Context, tenant string) (Options, error) {
+		options, found := tenants[tenant]
+		if !found {
+			return Options{}, errors.New("unknown tenant")
+		}
+		return options, nil
+	})
+	s := New(Options{
+		PolicyResolver: cache.Resolver(tenantFromRequest),
+	})
+
+	cases := []struct {
+		name       string
+		tenant     string
+		method     string
+		reqHeaders http.Header
+		resHeaders http.Header
+	}{
+		{
+			"AcmeOrigin",
+			"acme",
+			http.MethodGet,
+			http.Header{"Origin": {"https://acme.com"}},
+			http.Header{
+				"Vary":                             {"Origin"},
+				"Access-Control-Allow-Origin":      {"https://acme.com"},
+				"Access-Control-Allow-Credentials": {"true"},
+			},
+		},
+		{
+			"AcmeOtherTenantOrigin",
+			"acme",
+			http.MethodGet,
+			http.Header{"Origin": {"https://globex.com"}},
+			http.Header{"Vary": {"Origin"}},
+		},
+		{
+			"GlobexPreflight",
+			"globex",
+			http.MethodOptions,
+			http.Header{
+				"Origin":                        {"https://globex.com"},
+				"Access-Control-Request-Method": {http.MethodPut},
+			},
+			http.Header{
+				"Vary":                         {"Origin, Access-Control-Request-Method, Access-Control-Request-Headers"},
+				"Access-Control-Allow-Origin":  {"https://globex.com"},
+				"Access-Control-Allow-Methods": {http.MethodPut},
+			},
+		},
+		{
+			"UnknownTenant",
+			"initech",
+			http.MethodGet,
+			http.Header{"Origin": {"https://acme.com"}},
+			http.Header{"Vary": {"Origin"}},
+		},
+		{
+			"NoTenant",
+			"",
+			http.MethodGet,
+			http.Header{"Origin": {"https://acme.com"}},
+			http.Header{"Vary": {"Origin"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+			if tc.tenant != "" {
+				req = req.WithContext(context.WithValue(req.Context(), tenantKey{}, tc.tenant))
+			}
+			req.Header = tc.reqHeaders
+			res := httptest.NewRecorder()
+			s.Handler(testHandler).ServeHTTP(res, req)
+			assertHeaders(t, res.Header(), tc.resHeaders)
+			if have, want := s.OriginAllowed(req), len(tc.resHeaders) > 1; have != want {
+				t.Errorf("OriginAllowed have: %t want: %t", have, want)
+			}
+		})
+	}
+	if cache.Len() != 2 {
+		t.Errorf("cache should hold 2 policies, got %d", cache.Len())
+	}
+}
+
+func TestPolicyCache(t *testing.T) {
+	loads := map[string]int{}
+	cache := NewPolicyCache(2, func(ctx context.Context, key string) (Options, error) {
+		loads[key]++
+		return Options{AllowedOrigins: []string{"https://" + key}}, nil
+	})
+	get := func(key string) *Cors {
+		t.Helper()
+		c, err := cache.Get(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Get(%q): unexpected error: %v", key, err)
+		}
+		return c
+	}
+
+	a := get("a.com")
+	if get("a.com") != a || loads["a.com"] != 1 {
+		t.Error("Get should return the cached policy")
+	}
+	if !a.allowedOrigins.contains("https://a.com") {
+		t.Error("Get should compile the loaded options")
+	}
+	get("b.com")
+	get("a.com") // a.com is now the most recently used
+	get("c.com") // evicts b.com
+	if cache.Len() != 2 {
+		t.Errorf("cache should be bounded to 2 policies, got %d", cache.Len())
+	}
+	get("a.com")
+	get("b.com")
+	if loads["a.com"] != 1 || loads["b.com"] != 2 {
+		t.Errorf("least recently used policy should be evicted, loads: %v", loads)
+	}
+
+	cache.Invalidate("b.com")
+	get("b.com")
+	if loads["b.com"] != 3 {
+		t.Errorf("invalidated policy should be reloaded, loads: %v", loads)
+	}
+	cache.Purge()
+	if cache.Len() != 0 {
+		t.Errorf("purged cache should be empty, got %d", cache.Len())
+	}
+}
@@ -0,0 +1,173 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// A Rule allows or denies cross-origin requests from a set of origins, with
+// its own settings. Rules are evaluated in order and the first rule matching
+// the origin of a request decides; see Options.Rules.
+type Rule struct {
+	// Name identifies the rule in debug logs.
+	Name string `json:"name,omitempty"`
+	// Origins lists the origins the rule applies to, using the same syntax
+	// as Options.AllowedOrigins. The special "*" value matches any origin.
+	Origins []string `json:"origins,omitempty"`
+	// Deny denies the requests from the matching origins instead of
+	// allowing them. The settings below are then irrelevant.
+	Deny bool `json:"deny,omitempty"`
+	// AllowedMethods overrides Options.AllowedMethods when not empty.
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+	// AllowedHeaders overrides Options.AllowedHeaders when not empty.
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+	// ExposedHeaders overrides Options.ExposedHeaders when not empty.
+	ExposedHeaders []string `json:"exposedHeaders,omitempty"`
+	// MaxAge overrides Options.MaxAge when not 0.
+	MaxAge int `json:"maxAge,omitempty"`
+	// AllowCredentials indicates whether the matching origins can include
+	// user credentials. Contrary to the other settings, it is not inherited
+	// from Options.
+	AllowCredentials bool `json:"allowCredentials,omitempty"`
+}
+
+// An OriginGroup is a named set of origins sharing settings which override
+// the ones of Options for the requests from these origins; see
+// Options.OriginGroups.
+type OriginGroup struct {
+	// Name identifies the group. It is required and must be unique.
+	Name string
+	// Origins lists the origins of the group, using the same syntax as
+	// Options.AllowedOrigins.
+	Origins []string
+	// AllowedMethods overrides Options.AllowedMethods when not empty.
+	AllowedMethods []string
+	// ExposedHeaders overrides Options.ExposedHeaders when not empty.
+	ExposedHeaders []string
+	// MaxAge overrides Options.MaxAge when not 0.
+	MaxAge int
+	// AllowCredentials indicates whether the origins of the group can
+	// include user credentials. Contrary to the other settings, it is not
+	// inherited from Options.
+	AllowCredentials bool
+}
+
+// rule returns the allow rule equivalent to the group.
+func (g OriginGroup) rule() Rule {
+	return Rule{
+		Name:             g.Name,
+		Origins:          g.Origins,
+		AllowedMethods:   g.AllowedMethods,
+		ExposedHeaders:   g.ExposedHeaders,
+		MaxAge:           g.MaxAge,
+		AllowCredentials: g.AllowCredentials,
+	}
+}
+
+// validateGroups reports the groups without a name or with a duplicate name.
+func validateGroups(groups []OriginGroup) []error {
+	var errs []error
+	seen := map[string]bool{}
+	for i, g := range groups {
+		switch {
+		case g.Name == "":
+			errs = append(errs, fmt.Errorf("cors: origin group #%d has no name", i))
+		case seen[g.Name]:
+			errs = append(errs, fmt.Errorf("cors: duplicate origin group name %q", g.Name))
+		}
+		seen[g.Name] = true
+	}
+	return errs
+}
+
+// rule is a compiled Rule, an OriginGroup, or the compiled origin options.
+type rule struct {
+	name string
+	// Set to true when the rule was compiled from an OriginGroup
+	group bool
+	deny  bool
+	// Set to true when the rule allows all origins, in which case allowed
+	// requests get "Access-Control-Allow-Origin: *"
+	all    bool
+	match  func(r *http.Request, origin string) (bool, []string)
+	policy *policy
+	// Origins matched by the rule, nil when it uses a function
+	origins *originList
+}
+
+// originDecision is the outcome of the evaluation of a request origin against
+// the rules.
+type originDecision struct {
+	allowed     bool
+	varyHeaders []string
+	// First rule matching the origin, nil if none did
+	rule *rule
+}
+
+// compileRule compiles a Rule, inheriting the unset settings from the
+// options' policy. Invalid origins are reported and ignored.
+func (c *Cors) compileRule(r Rule, index int, schemes []string) (*rule, []error) {
+	rl := &rule{
+		name: r.Name,
+		deny: r.Deny,
+	}
+	if rl.name == "" {
+		rl.name = "#" + strconv.Itoa(index)
+	}
+	if !rl.deny {
+		rl.policy = c.policy.override(r.AllowedMethods, r.AllowedHeaders, r.ExposedHeaders, r.MaxAge, r.AllowCredentials)
+	}
+	if slices.Contains(r.Origins, "*") {
+		rl.all = true
+		rl.match = func(_ *http.Request, origin string) (bool, []string) {
+			return origin != nullOrigin || !c.nullOrigin.ExcludeFromAllowAll, nil
+		}
+		return rl, nil
+	}
+	var errs []error
+	origins := &originList{}
+	for _, origin := range r.Origins {
+		if err := origins.add(origin, schemes); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	rl.origins = origins
+	rl.match = func(_ *http.Request, origin string) (bool, []string) {
+		return origins.contains(origin), nil
+	}
+	return rl, errs
+}
+
+// evaluateOrigin evaluates the rules in order, the first rule matching the
+// origin taking the decision. Vary headers are collected from all the
+// evaluated rules.
+func (c *Cors) evaluateOrigin(r *http.Request, origin string) (d originDecision) {
+	for _, rl := range c.rules {
+		matched, varyHeaders := rl.match(r, origin)
+		d.varyHeaders = append(d.varyHeaders, varyHeaders...)
+		if matched {
+			d.allowed = !rl.deny
+			d.rule = rl
+			return d
+		}
+	}
+	return d
+}
+
+// String describes the rule for logging purposes.
+func (rl *rule) String() string {
+	if rl.group {
+		return "group '" + rl.name + "'"
+	}
+	return "rule '" + rl.name + "'"
+}
+
+// rejection describes why an origin was not allowed, for logging purposes.
+func (d originDecision) rejection() string {
+	if d.rule != nil && d.rule.deny {
+		return "denied by " + d.rule.String()
+	}
+	return "not allowed"
+}
@@ -0,0 +1,314 @@
+package cors
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	options := Options{
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		MaxAge:         10,
+		Rules: []Rule{
+			{
+				Name:             "old-partner",
+				Origins:          []string{"https://old.partner.com"},
+				Deny:             true,
+				AllowCredentials: true,
+			},
+			{
+				Name:             "partners",
+				Origins:          []string{"https://*.partner.com"},
+				AllowedMethods:   []string{http.MethodGet, http.MethodDelete},
+				MaxAge:           3600,
+				AllowCredentials: true,
+			},
+			{
+				Name:    "public",
+				Origins: []string{"*"},
+			},
+		},
+	}
+	cases := []struct {
+		name       string
+		method     string
+		reqHeaders http.Header
+		resHeaders http.Header
+	}{
+		{
+			"PartnerPreflight",
+			http.MethodOptions,
+			http.Header{
+				"Origin":                        {"https://app.partner.com"},
+				"Access-Control-Request-Method": {http.MethodDelete},
+			},
+			http.Header{
+				"Vary":                             {"Origin, Access-Control-Request-Method, Access-Control-Request-Headers"},
+				"Access-Control-Allow-Origin":      {"https://app.partner.com"},
+				"Access-Control-Allow-Methods":     {http.MethodDelete},
+				"Access-Control-Allow-Credentials": {"true"},
+				"Access-Control-Max-Age":           {"3600"},
+			},
+		},
+		{
+			"PartnerMethodNotAllowed",
+			http.MethodOptions,
+			http.Header{
+				"Origin":                        {"https://app.partner.com"},
+				"Access-Control-Request-Method": {http.MethodPost},
+			},
+			http.Header{
+				"Vary": {"Origin, Access-Control-Request-Method, Access-Control-Request-Headers"},
+			},
+		},
+		{
+			"DeniedPartner",
+			http.MethodGet,
+			http.Header{
+				"Origin": {"https://old.partner.com"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+		},
+		{
+			"PublicPreflight",
+			http.MethodOptions,
+			http.Header{
+				"Origin":                        {"https://foobar.com"},
+				"Access-Control-Request-Method": {http.MethodPost},
+			},
+			http.Header{
+				"Vary":                         {"Origin, Access-Control-Request-Method, Access-Control-Request-Headers"},
+				"Access-Control-Allow-Origin":  {"*"},
+				"Access-Control-Allow-Methods": {http.MethodPost},
+				"Access-Control-Max-Age":       {"10"},
+			},
+		},
+		{
+			"PublicMethodNotAllowed",
+			http.MethodOptions,
+			http.Header{
+				"Origin":                        {"https://foobar.com"},
+				"Access-Control-Request-Method": {http.MethodDelete},
+			},
+			http.Header{
+				"Vary": {"Origin, Access-Control-Request-Method, Access-Control-Request-Headers"},
+			},
+		},
+	}
+	s := New(options)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+			req.Header = tc.reqHeaders
+			res := httptest.NewRecorder()
+			s.Handler(testHandler).ServeHTTP(res, req)
+			assertHeaders(t, res.Header(), tc.resHeaders)
+		})
+	}
+}
+
+func TestRulesOrder(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://foobar.com"},
+		DeniedOrigins:  []string{"https://denied.com"},
+		Rules: []Rule{
+			{Origins: []string{"https://denied.com", "https://foobar.com"}},
+			{Origins: []string{"https://*.foobar.com"}, Deny: true},
+		},
+	})
+	for origin, want := range map[string]bool{
+		"https://denied.com":     false, // DeniedOrigins come first
+		"https://foobar.com":     true,
+		"https://www.foobar.com": false,
+		"https://barbaz.com":     false, // no catch-all with rules
+	} {
+		if allowed, _ := s.isOriginAllowed(nil, origin); allowed != want {
+			t.Errorf("origin %q allowed = %t, want %t", origin, allowed, want)
+		}
+	}
+}
+
+func TestRulesVary(t *testing.T) {
+	s := New(Options{
+		Rules: []Rule{
+			{Origins: []string{"https://foobar.com"}},
+		},
+		AllowOriginVaryRequestFunc: func(r *http.Request, origin string) (bool, []string) {
+			return r.Header.Get("X-Allow") == "true", []string{"X-Allow"}
+		},
+	})
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://barbaz.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), http.Header{
+		"Vary": {"Origin", "X-Allow"},
+	})
+}
+
+func TestRulesInvalidOriginWarning(t *testing.T) {
+	logger := &testLogger{buf: &bytes.Buffer{}}
+	New(Options{
+		Rules:  []Rule{{Name: "partners", Origins: []string{"partner.com:8443"}}},
+		Logger: logger,
+	})
+	if !strings.Contains(logger.buf.String(), `rule 'partners': cors: invalid origin "partner.com:8443"`) {
+		t.Errorf("New should warn about invalid rule origins, got %q", logger.buf.String())
+	}
+	if err := (Options{Rules: []Rule{{Origins: []string{"partner.com:8443"}}}}).Validate(); err == nil {
+		t.Error("Validate should report invalid rule origins")
+	}
+}
+
+func TestPolicyOverride(t *testing.T) {
+	var base policy
+	base.setAllowedMethods(nil)
+	base.setAllowedHeaders([]string{"X-Foo"})
+	base.setExposedHeaders([]string{"X-Bar"})
+	base.setMaxAge(10)
+	base.allowCredentials = true
+
+	p := base.override(nil, nil, nil, 0, false)
+	if !p.isMethodAllowed(http.MethodGet) || p.isMethodAllowed(http.MethodDelete) {
+		t.Error("override should inherit allowed methods")
+	}
+	if !p.allowedHeaders.Accepts([]string{"x-foo"}) || p.exposedHeaders[0] != "X-Bar" || p.maxAge[0] != "10" {
+		t.Error("override should inherit headers and max-age")
+	}
+	if p.allowCredentials {
+		t.Error("override should not inherit credentials")
+	}
+
+	p = base.override([]string{http.MethodDelete}, []string{"*"}, []string{"x-baz"}, -1, true)
+	if p.isMethodAllowed(http.MethodGet) || !p.isMethodAllowed(http.MethodDelete) {
+		t.Error("override should override allowed methods")
+	}
+	if !p.allowedHeadersAll || p.exposedHeaders[0] != "X-Baz" || p.maxAge[0] != "0" || !p.allowCredentials {
+		t.Error("override should override headers, max-age and credentials")
+	}
+	if base.allowedHeadersAll || base.isMethodAllowed(http.MethodDelete) {
+		t.Error("override should not modify the base policy")
+	}
+}
+
+func TestOriginGroups(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://foobar.com"},
+		ExposedHeaders: []string{"X-Request-Id"},
+		OriginGroups: []OriginGroup{
+			{
+				Name:             "partners",
+				Origins:          []string{"https://*.partner.com"},
+				MaxAge:           86400,
+				AllowCredentials: true,
+			},
+			{
+				Name:           "admin",
+				Origins:        []string{"https://admin.foobar.com"},
+				AllowedMethods: []string{http.MethodGet, http.MethodDelete},
+				ExposedHeaders: []string{"X-Total-Count"},
+			},
+		},
+	})
+	cases := []struct {
+		name       string
+		method     string
+		reqHeaders http.Header
+		resHeaders http.Header
+	}{
+		{
+			"PartnerPreflight",
+			http.MethodOptions,
+			http.Header{
+				"Origin":                        {"https://app.partner.com"},
+				"Access-Control-Request-Method": {http.MethodGet},
+			},
+			http.Header{
+				"Vary":                             {"Origin, Access-Control-Request-Method, Access-Control-Request-Headers"},
+				"Access-Control-Allow-Origin":      {"https://app.partner.com"},
+				"Access-Control-Allow-Methods":     {http.MethodGet},
+				"Access-Control-Allow-Credentials": {"true"},
+				"Access-Control-Max-Age":           {"86400"},
+			},
+		},
+		{
+			"PartnerActual",
+			http.MethodGet,
+			http.Header{
+				"Origin": {"https://app.partner.com"},
+			},
+			http.Header{
+				"Vary":                             {"Origin"},
+				"Access-Control-Allow-Origin":      {"https://app.partner.com"},
+				"Access-Control-Allow-Credentials": {"true"},
+				"Access-Control-Expose-Headers":    {"X-Request-Id"},
+			},
+		},
+		{
+			"AdminDelete",
+			http.MethodDelete,
+			http.Header{
+				"Origin": {"https://admin.foobar.com"},
+			},
+			http.Header{
+				"Vary":                          {"Origin"},
+				"Access-Control-Allow-Origin":   {"https://admin.foobar.com"},
+				"Access-Control-Expose-Headers": {"X-Total-Count"},
+			},
+		},
+		{
+			"PublicDelete",
+			http.MethodDelete,
+			http.Header{
+				"Origin": {"https://foobar.com"},
+			},
+			http.Header{
+				"Vary": {"Origin"},
+			},
+		},
+		{
+			"PublicActual",
+			http.MethodGet,
+			http.Header{
+				"Origin": {"https://foobar.com"},
+			},
+			http.Header{
+				"Vary":                          {"Origin"},
+				"Access-Control-Allow-Origin":   {"https://foobar.com"},
+				"Access-Control-Expose-Headers": {"X-Request-Id"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+			req.Header = tc.reqHeaders
+			res := httptest.NewRecorder()
+			s.Handler(testHandler).ServeHTTP(res, req)
+			assertHeaders(t, res.Header(), tc.resHeaders)
+		})
+	}
+}
+
+func TestOriginGroupsValidate(t *testing.T) {
+	err := Options{
+		OriginGroups: []OriginGroup{
+			{Name: "partners"},
+			{Name: "partners"},
+			{Origins: []string{"partner.com:8443"}},
+		},
+	}.Validate()
+	if err == nil {
+		t.Fatal("Validate should report invalid groups")
+	}
+	for _, want := range []string{`duplicate origin group name "partners"`, "origin group #2 has no name", `invalid origin "partner.com:8443"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error %q should contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cors
+
+import (
+	"strings"
+)
+
+// Non-HTTP origin schemes used by browser extensions and hybrid mobile apps.
+const (
+	SchemeChromeExtension    = "chrome-extension"
+	SchemeMozExtension       = "moz-extension"
+	SchemeSafariWebExtension = "safari-web-extension"
+	SchemeCapacitor          = "capacitor"
+	SchemeIonic              = "ionic"
+)
+
+// originSchemes maps the non-HTTP origin schemes with first-class support to
+// a function validating the host of their origins. Origins of these schemes
+// have no port and only accept a wildcard for the whole host (i.e.:
+// moz-extension://*) as their host is an opaque identifier.
+var originSchemes = map[string]func(host string) bool{
+	SchemeChromeExtension:    isChromeExtensionID,
+	SchemeMozExtension:       isUUID,
+	SchemeSafariWebExtension: isUUID,
+	SchemeCapacitor:          isAppHost,
+	SchemeIonic:              isAppHost,
+}
+
+// ChromeExtension returns the origin of the Chrome (or Chromium based
+// browser) extension with the given ID.
+func ChromeExtension(id string) string {
+	return SchemeChromeExtension + "://" + id
+}
+
+// AnyChromeExtension returns an origin matching any Chrome extension.
+func AnyChromeExtension() string {
+	return SchemeChromeExtension + "://" + "*"
+}
+
+// MozExtension returns the origin of the Firefox extension with the given
+// internal UUID. Note that Firefox generates a different UUID per install;
+// use AnyMozExtension to allow an extension for all its users.
+func MozExtension(uuid string) string {
+	return SchemeMozExtension + "://" + uuid
+}
+
+// AnyMozExtension returns an origin matching any Firefox extension.
+func AnyMozExtension() string {
+	return SchemeMozExtension + "://" + "*"
+}
+
+// SafariWebExtension returns the origin of the Safari web extension with the
+// given UUID.
+func SafariWebExtension(uuid string) string {
+	return SchemeSafariWebExtension + "://" + uuid
+}
+
+// AnySafariWebExtension returns an origin matching any Safari web extension.
+func AnySafariWebExtension() string {
+	return SchemeSafariWebExtension + "://" + "*"
+}
+
+// Capacitor returns the origin of Capacitor apps using the default hostname
+// (capacitor://localhost).
+func Capacitor() string {
+	return SchemeCapacitor + "://localhost"
+}
+
+// Ionic returns the origin of Ionic apps using the default hostname
+// (ionic://localhost).
+func Ionic() string {
+	return SchemeIonic + "://localhost"
+}
+
+// checkSchemeOrigin validates the host part of an origin using one of the
+// originSchemes. It returns an empty reason if the origin is valid, and
+// whether the scheme is one of the originSchemes.
+func checkSchemeOrigin(scheme, host string) (reason string, known bool) {
+	valid, known := originSchemes[scheme]
+	switch {
+	case !known:
+		return "", false
+	case host == "*":
+		return "", true
+	case strings.IndexByte(host, '*') >= 0:
+		return scheme + " origins only accept a wildcard for the whole host", true
+	case strings.IndexByte(host, ':') >= 0:
+		return scheme + " origins have no port", true
+	case !valid(host):
+		return "malformed " + scheme + " host", true
+	}
+	return "", true
+}
+
+// isChromeExtensionID reports whether id is a Chrome extension ID: 32
+// characters in the a-p range.
+func isChromeExtensionID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 'a' || id[i] > 'p' {
+			return false
+		}
+	}
+	return true
+}
+
+// isUUID reports whether s is a UUID in its canonical textual form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isAppHost reports whether host is a plausible host name for a hybrid app.
+func isAppHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	for i := 0; i < len(host); i++ {
+		switch c := host[i]; {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package cors
+
+import (
+	"testing"
+)
+
+const (
+	testChromeExtensionID = "abcdefghijklmnopabcdefghijklmnop"
+	testExtensionUUID     = "2c127fa4-62c7-7e4f-90e5-472b45eecfdc"
+)
+
+func TestSchemeOrigins(t *testing.T) {
+	cases := []struct {
+		entry  string
+		origin string
+		match  bool
+	}{
+		{ChromeExtension(testChromeExtensionID), "chrome-extension://" + testChromeExtensionID, true},
+		{ChromeExtension(testChromeExtensionID), "chrome-extension://ponmlkjihgfedcbaponmlkjihgfedcba", false},
+		{AnyChromeExtension(), "chrome-extension://" + testChromeExtensionID, true},
+		{AnyChromeExtension(), "moz-extension://" + testExtensionUUID, false},
+		{MozExtension(testExtensionUUID), "moz-extension://" + testExtensionUUID, true},
+		{AnyMozExtension(), "moz-extension://" + testExtensionUUID, true},
+		{AnyMozExtension(), "https://moz-extension.com", false},
+		{SafariWebExtension("2C127FA4-62C7-7E4F-90E5-472B45EECFDC"), "safari-web-extension://2C127FA4-62C7-7E4F-90E5-472B45EECFDC", true},
+		{AnySafariWebExtension(), "safari-web-extension://" + testExtensionUUID, true},
+		{Capacitor(), "capacitor://localhost", true},
+		{Capacitor(), "ionic://localhost", false},
+		{Ionic(), "ionic://localhost", true},
+		{"capacitor://app.example.com", "capacitor://app.example.com", true},
+	}
+	for _, tc := range cases {
+		var l originList
+		if err := l.add(tc.entry, defaultSchemes); err != nil {
+			t.Errorf("add(%q): unexpected error: %v", tc.entry, err)
+			continue
+		}
+		if match := l.contains(tc.origin); match != tc.match {
+			t.Errorf("%q matching %q = %t, want %t", tc.entry, tc.origin, match, tc.match)
+		}
+	}
+}
+
+func TestSchemeOriginsRejects(t *testing.T) {
+	for _, entry := range []string{
+		ChromeExtension("too-short"),
+		ChromeExtension("abcdefghijklmnopabcdefghijklmnoz"),
+		"chrome-extension://abcd*",
+		MozExtension("not-a-uuid"),
+		MozExtension(testExtensionUUID + ":8080"),
+		SafariWebExtension("2c127fa4_62c7_7e4f_90e5_472b45eecfdc"),
+		"capacitor://localhost:8080",
+		"ionic://local_host",
+	} {
+		var l originList
+		if err := l.add(entry, defaultSchemes); err == nil {
+			t.Errorf("add(%q) should fail", entry)
+		}
+	}
+}
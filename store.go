@@ -0,0 +1,188 @@
+package cors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/cors/internal"
+)
+
+// An OriginStore decides whether origins are allowed from an external source
+// like a database or an internal registry; see Options.OriginStore.
+// Implementations performing I/O should be wrapped with NewOriginStoreCache.
+type OriginStore interface {
+	// Lookup reports whether origin is allowed. The Cors handler passes
+	// origins in their normalized form: lowercase, without default port, with
+	// punycode host names and canonical IPv6 literals.
+	Lookup(ctx context.Context, origin string) (allowed bool, err error)
+}
+
+// OriginStoreCacheOptions configures an OriginStoreCache.
+type OriginStoreCacheOptions struct {
+	// PositiveTTL is how long an allowed origin is cached.
+	// Default value is 5 minutes.
+	PositiveTTL time.Duration
+	// NegativeTTL is how long a disallowed origin is cached.
+	// Default value is 1 minute.
+	NegativeTTL time.Duration
+	// StaleTTL is how long an expired answer keeps being served while it
+	// can't be refreshed. Past this delay, the origin is looked up on the
+	// request path again, and gets the fail-open or fail-closed answer if
+	// the store still fails.
+	// Default value is 10 minutes.
+	StaleTTL time.Duration
+	// MaxEntries bounds the number of cached origins, the least recently
+	// used ones being evicted first.
+	// Default value is 10000.
+	MaxEntries int
+	// FailOpen allows the origins the store fails to look up and which have
+	// no cached answer. By default, such origins are disallowed.
+	FailOpen bool
+	// LookupTimeout bounds the lookups in the wrapped store, which are shared
+	// by the concurrent requests for the same origin and thus not canceled
+	// with any of them. A request stops waiting for a lookup when its own
+	// context is done or when this timeout expires, whichever comes first,
+	// and gets the fail-open or fail-closed answer.
+	// Default value is 5 seconds.
+	LookupTimeout time.Duration
+}
+
+// OriginStoreCache is an OriginStore caching the answers of another store.
+//
+// Once cached, an origin isn't looked up on the request path again while
+// its answer can be refreshed: when the answer expires, it keeps being served
+// while it is refreshed in the background, until the refresh succeeds or the
+// answer is older than the stale TTL. Concurrent lookups of the same origin
+// are de-duplicated.
+// It is safe for concurrent use.
+type OriginStoreCache struct {
+	store       OriginStore
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+	staleTTL    time.Duration
+	failOpen    bool
+	timeout     time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries *internal.LRU[string, *originStoreEntry]
+	flights internal.Singleflight[string, bool]
+}
+
+type originStoreEntry struct {
+	allowed    bool
+	expires    time.Time
+	refreshing bool
+}
+
+// NewOriginStoreCache wraps store with a caching layer.
+func NewOriginStoreCache(store OriginStore, options OriginStoreCacheOptions) *OriginStoreCache {
+	s := &OriginStoreCache{
+		store:       store,
+		positiveTTL: options.PositiveTTL,
+		negativeTTL: options.NegativeTTL,
+		staleTTL:    options.StaleTTL,
+		failOpen:    options.FailOpen,
+		timeout:     options.LookupTimeout,
+		now:         time.Now,
+	}
+	if s.timeout <= 0 {
+		s.timeout = 5 * time.Second
+	}
+	if s.positiveTTL <= 0 {
+		s.positiveTTL = 5 * time.Minute
+	}
+	if s.negativeTTL <= 0 {
+		s.negativeTTL = time.Minute
+	}
+	if s.staleTTL <= 0 {
+		s.staleTTL = 10 * time.Minute
+	}
+	maxEntries := options.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	s.entries = internal.NewLRU[string, *originStoreEntry](maxEntries)
+	return s
+}
+
+// Lookup reports whether origin is allowed, from the cache when possible.
+// When the wrapped store fails and there is no cached answer, or only one
+// older than the stale TTL, the error is returned along with the fail-open
+// or fail-closed answer.
+func (s *OriginStoreCache) Lookup(ctx context.Context, origin string) (bool, error) {
+	s.mu.Lock()
+	e, found := s.entries.Get(origin)
+	if found {
+		now := s.now()
+		if now.Before(e.expires.Add(s.staleTTL)) {
+			allowed := e.allowed
+			if !now.Before(e.expires) && !e.refreshing {
+				e.refreshing = true
+				go s.refresh(ctx, origin, e)
+			}
+			s.mu.Unlock()
+			return allowed, nil
+		}
+		// Too stale to be served
+		s.entries.Remove(origin)
+	}
+	s.mu.Unlock()
+
+	allowed, err := s.fetch(ctx, origin)
+	if err != nil {
+		return s.failOpen, err
+	}
+	return allowed, nil
+}
+
+// refresh looks up an expired origin in the background.
+func (s *OriginStoreCache) refresh(ctx context.Context, origin string, e *originStoreEntry) {
+	if _, err := s.fetch(ctx, origin); err != nil {
+		s.mu.Lock()
+		e.refreshing = false
+		s.mu.Unlock()
+	}
+}
+
+// fetch looks up origin in the wrapped store, de-duplicating concurrent
+// lookups, and caches the answer. The lookup is shared by the concurrent
+// callers, so it isn't canceled with the request of the first one but
+// bounded by the lookup timeout, like the wait of each caller.
+func (s *OriginStoreCache) fetch(ctx context.Context, origin string) (bool, error) {
+	lookupCtx := context.WithoutCancel(ctx)
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.flights.Do(ctx, origin, func() (bool, error) {
+		ctx, cancel := context.WithTimeout(lookupCtx, s.timeout)
+		defer cancel()
+		allowed, err := s.store.Lookup(ctx, origin)
+		if err != nil {
+			return false, err
+		}
+		ttl := s.negativeTTL
+		if allowed {
+			ttl = s.positiveTTL
+		}
+		s.mu.Lock()
+		s.entries.Add(origin, &originStoreEntry{allowed: allowed, expires: s.now().Add(ttl)})
+		s.mu.Unlock()
+		return allowed, nil
+	})
+}
+
+// Invalidate removes origin from the cache, so it is looked up again on
+// next use.
+func (s *OriginStoreCache) Invalidate(origin string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.Remove(origin)
+}
+
+// Purge removes all the origins from the cache.
+func (s *OriginStoreCache) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.Purge()
+}
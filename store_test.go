@@ -0,0 +1,330 @@
+package cors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeOriginStore struct {
+	mu      sync.Mutex
+	allowed map[string]bool
+	err     error
+	lookups atomic.Int32
+	block   chan struct{}
+}
+
+func (s *fakeOriginStore) Lookup(ctx context.Context, origin string) (bool, error) {
+	s.lookups.Add(1)
+	if s.block != nil {
+		<-s.block
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.allowed[origin], s.err
+}
+
+func (s *fakeOriginStore) set(origin string, allowed bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.allowed[origin] = allowed
+	s.err = err
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// waitLookups waits for the background refreshes to reach the store.
+func waitLookups(t *testing.T, store *fakeOriginStore, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for store.lookups.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d lookups, want %d", store.lookups.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOriginStoreCacheTTL(t *testing.T) {
+	store := &fakeOriginStore{allowed: map[string]bool{"https://foo.com": true}}
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := NewOriginStoreCache(store, OriginStoreCacheOptions{
+		PositiveTTL: time.Minute,
+		NegativeTTL: time.Second,
+	})
+	cache.now = clock.Now
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if allowed, err := cache.Lookup(ctx, "https://foo.com"); !allowed || err != nil {
+			t.Fatalf("Lookup = %v, %v, want true, nil", allowed, err)
+		}
+		if allowed, err := cache.Lookup(ctx, "https://bar.com"); allowed || err != nil {
+			t.Fatalf("Lookup = %v, %v, want false, nil", allowed, err)
+		}
+	}
+	if got := store.lookups.Load(); got != 2 {
+		t.Fatalf("got %d lookups, want 2", got)
+	}
+
+	// Only the negative answer expires; it is served stale while refreshed
+	store.set("https://bar.com", true, nil)
+	clock.Advance(2 * time.Second)
+	cache.Lookup(ctx, "https://foo.com")
+	if allowed, _ := cache.Lookup(ctx, "https://bar.com"); allowed {
+		t.Error("expired answer should be served during the refresh")
+	}
+	waitLookups(t, store, 3)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if allowed, _ := cache.Lookup(ctx, "https://bar.com"); allowed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed answer never served")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := store.lookups.Load(); got != 3 {
+		t.Errorf("got %d lookups, want 3", got)
+	}
+
+	cache.Invalidate("https://foo.com")
+	cache.Lookup(ctx, "https://foo.com")
+	if got := store.lookups.Load(); got != 4 {
+		t.Errorf("got %d lookups after Invalidate, want 4", got)
+	}
+}
+
+func TestOriginStoreCacheRefreshError(t *testing.T) {
+	store := &fakeOriginStore{allowed: map[string]bool{"https://foo.com": true}}
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := NewOriginStoreCache(store, OriginStoreCacheOptions{PositiveTTL: time.Minute, StaleTTL: 5 * time.Minute})
+	cache.now = clock.Now
+	ctx := context.Background()
+
+	cache.Lookup(ctx, "https://foo.com")
+	store.set("https://foo.com", false, errors.New("unavailable"))
+	clock.Advance(2 * time.Minute)
+	for i := int32(2); i < 5; i++ {
+		if allowed, err := cache.Lookup(ctx, "https://foo.com"); !allowed || err != nil {
+			t.Fatalf("Lookup = %v, %v, want stale true, nil", allowed, err)
+		}
+		// A failed refresh is retried on next use
+		waitLookups(t, store, i)
+	}
+
+	// Past the stale TTL, the stale answer isn't served anymore
+	clock.Advance(4 * time.Minute)
+	if allowed, err := cache.Lookup(ctx, "https://foo.com"); allowed || err == nil {
+		t.Errorf("Lookup = %v, %v, want the fail-closed answer and the error", allowed, err)
+	}
+	// until the store recovers
+	store.set("https://foo.com", true, nil)
+	if allowed, err := cache.Lookup(ctx, "https://foo.com"); !allowed || err != nil {
+		t.Errorf("Lookup = %v, %v, want true, nil", allowed, err)
+	}
+}
+
+func TestOriginStoreCacheFailure(t *testing.T) {
+	for _, failOpen := range []bool{false, true} {
+		store := &fakeOriginStore{allowed: map[string]bool{}, err: errors.New("unavailable")}
+		cache := NewOriginStoreCache(store, OriginStoreCacheOptions{FailOpen: failOpen})
+		allowed, err := cache.Lookup(context.Background(), "https://foo.com")
+		if allowed != failOpen || err == nil {
+			t.Errorf("FailOpen %v: Lookup = %v, %v", failOpen, allowed, err)
+		}
+		// Errors are not cached
+		cache.Lookup(context.Background(), "https://foo.com")
+		if got := store.lookups.Load(); got != 2 {
+			t.Errorf("FailOpen %v: got %d lookups, want 2", failOpen, got)
+		}
+	}
+}
+
+func TestOriginStoreCacheDedup(t *testing.T) {
+	store := &fakeOriginStore{
+		allowed: map[string]bool{"https://foo.com": true},
+		block:   make(chan struct{}),
+	}
+	cache := NewOriginStoreCache(store, OriginStoreCacheOptions{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if allowed, err := cache.Lookup(context.Background(), "https://foo.com"); !allowed || err != nil {
+				t.Errorf("Lookup = %v, %v, want true, nil", allowed, err)
+			}
+		}()
+	}
+	waitLookups(t, store, 1)
+	time.Sleep(10 * time.Millisecond)
+	close(store.block)
+	wg.Wait()
+	if got := store.lookups.Load(); got != 1 {
+		t.Errorf("got %d lookups, want 1", got)
+	}
+}
+
+func TestOriginStoreCacheDedupCanceled(t *testing.T) {
+	store := &fakeOriginStore{
+		allowed: map[string]bool{"https://foo.com": true},
+		block:   make(chan struct{}),
+	}
+	cache := NewOriginStoreCache(store, OriginStoreCacheOptions{})
+
+	// The request of the caller making the lookup is canceled while the
+	// other callers wait for it
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := make(chan error)
+	go func() {
+		_, err := cache.Lookup(ctx, "https://foo.com")
+		canceled <- err
+	}()
+	waitLookups(t, store, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if allowed, err := cache.Lookup(context.Background(), "https://foo.com"); !allowed || err != nil {
+				t.Errorf("Lookup = %v, %v, want true, nil", allowed, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	// The canceled request doesn't wait for the lookup
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v for the canceled request, want %v", err, context.Canceled)
+	}
+	close(store.block)
+	wg.Wait()
+	if got := store.lookups.Load(); got != 1 {
+		t.Errorf("got %d lookups, want 1", got)
+	}
+}
+
+func TestOriginStoreCacheHung(t *testing.T) {
+	// A store which never returns
+	store := &fakeOriginStore{allowed: map[string]bool{}, block: make(chan struct{})}
+	defer close(store.block)
+	cache := NewOriginStoreCache(store, OriginStoreCacheOptions{LookupTimeout: 20 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	for _, ctx := range []context.Context{ctx, context.Background()} {
+		done := make(chan error)
+		go func() {
+			_, err := cache.Lookup(ctx, "https://foo.com")
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Lookup blocked by a hung store")
+		}
+	}
+}
+
+func TestOriginStoreCacheMaxEntries(t *testing.T) {
+	store := &fakeOriginStore{allowed: map[string]bool{}}
+	cache := NewOriginStoreCache(store, OriginStoreCacheOptions{MaxEntries: 2})
+	ctx := context.Background()
+	for _, origin := range []string{"https://a.com", "https://b.com", "https://c.com", "https://a.com"} {
+		cache.Lookup(ctx, origin)
+	}
+	if got := store.lookups.Load(); got != 4 {
+		t.Errorf("got %d lookups, want 4", got)
+	}
+	if got := cache.entries.Len(); got != 2 {
+		t.Errorf("got %d entries, want 2", got)
+	}
+}
+
+func TestOriginStoreOption(t *testing.T) {
+	store := &fakeOriginStore{allowed: map[string]bool{"https://foo.com": true}}
+	logger := &testLogger{buf: &bytes.Buffer{}}
+	s := New(Options{
+		Logger:         logger,
+		AllowedOrigins: []string{"https://bar.com"},
+		OriginStore:    NewOriginStoreCache(store, OriginStoreCacheOptions{}),
+	})
+	h := s.Handler(testHandler)
+
+	cases := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://foo.com", true},
+		{"https://bar.com", false},
+		{"https://baz.com", false},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+		req.Header.Add("Origin", tc.origin)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		allowed := res.Header().Get("Access-Control-Allow-Origin") == tc.origin
+		if allowed != tc.allowed {
+			t.Errorf("%s: got allowed %v, want %v", tc.origin, allowed, tc.allowed)
+		}
+	}
+
+	// Equivalent origins are looked up in their normalized form, malformed
+	// ones aren't looked up
+	lookups := store.lookups.Load()
+	for _, origin := range []string{"HTTPS://FOO.COM", "https://foo.com:443", "https://Foo.com"} {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+		req.Header.Add("Origin", origin)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if res.Header().Get("Access-Control-Allow-Origin") != origin {
+			t.Errorf("%s: should be allowed as https://foo.com", origin)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://foo.com/path")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got := store.lookups.Load(); got != lookups {
+		t.Errorf("got %d more lookups, want the cached answer of https://foo.com", got-lookups)
+	}
+
+	store.set("https://baz.com", false, errors.New("unavailable"))
+	req, _ = http.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://qux.com")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if !strings.Contains(logger.buf.String(), "Origin store lookup failed for 'https://qux.com'") {
+		t.Error("lookup error should be logged")
+	}
+}
@@ -23,12 +23,18 @@ type corsWrapper struct {
 // build transforms wrapped cors.Cors handler into Gin middleware.
 func (c corsWrapper) build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		written := ctx.Writer.Written()
 		c.HandlerFunc(ctx.Writer, ctx.Request)
-		if !c.optionsPassthrough &&
-			ctx.Request.Method == http.MethodOptions &&
+		if ctx.Request.Method == http.MethodOptions &&
 			ctx.GetHeader("Access-Control-Request-Method") != "" {
-			// Abort processing next Gin middlewares.
-			ctx.AbortWithStatus(c.optionsSuccessStatus)
+			if !c.optionsPassthrough {
+				// Abort processing next Gin middlewares.
+				ctx.AbortWithStatus(c.optionsSuccessStatus)
+			}
+		} else if !written && ctx.Writer.Written() {
+			// The request was rejected by the CSRF protection, whose
+			// response is written: the next handlers must not run.
+			ctx.Abort()
 		}
 	}
 }
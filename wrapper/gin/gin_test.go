@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/cors"
 )
 
 func init() {
@@ -71,3 +72,35 @@ func TestCorsWrapper_buildNotAbortsWhenPassthrough(t *testing.T) {
 		t.Error("Should not abort when OPTIONS passthrough enabled")
 	}
 }
+
+func TestCorsWrapper_buildAbortsWhenCSRFRejects(t *testing.T) {
+	reached := false
+	r := gin.New()
+	r.Use(New(Options{
+		AllowedOrigins: []string{"https://foo.com"},
+		CSRFProtection: cors.CSRFProtection{Enabled: true},
+	}))
+	r.POST("/foo", func(ctx *gin.Context) {
+		reached = true
+		ctx.Status(http.StatusOK)
+	})
+
+	for _, tc := range []struct {
+		origin  string
+		status  int
+		reached bool
+	}{
+		{"https://evil.com", http.StatusForbidden, false},
+		{"https://foo.com", http.StatusOK, true},
+	} {
+		reached = false
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "http://example.com/foo", nil)
+		req.Header.Set("Origin", tc.origin)
+		req.Header.Set("Sec-Fetch-Site", "cross-site")
+		r.ServeHTTP(res, req)
+		if res.Code != tc.status || reached != tc.reached {
+			t.Errorf("origin %s: got status %d, handler reached %t, want %d, %t", tc.origin, res.Code, reached, tc.status, tc.reached)
+		}
+	}
+}